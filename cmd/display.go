@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"net"
 
 	"github.com/buck119br/psss/psss"
 	"golang.org/x/sys/unix"
@@ -75,6 +76,12 @@ func GenericShow(protocal, af int) {
 	fmt.Printf("\n")
 	var ok bool
 	for _, si := range sis {
+		if procIndex != nil {
+			si.SetUpCgroup(procIndex)
+		}
+		if !cgroupMatch(&si) {
+			continue
+		}
 		switch protocal {
 		case psss.ProtocalTCP:
 			fmt.Printf("tcp")
@@ -96,6 +103,9 @@ func GenericShow(protocal, af int) {
 			fmt.Printf("6\t")
 		}
 		si.GenericInfoPrint()
+		if routing != nil && protocal != psss.ProtocalUnix {
+			routeInfoPrint(&si)
+		}
 		if *flagProcess && len(si.UserName) > 0 {
 			si.ProcInfoPrint()
 		}
@@ -120,3 +130,64 @@ func GenericShow(protocal, af int) {
 	}
 	fmt.Printf("\n")
 }
+
+// routeInfoPrint shows the route the remote address of the socket takes, as ip route get would.
+func routeInfoPrint(si *psss.SocketInfo) {
+	dst := net.ParseIP(si.RemoteAddr.Host)
+	if dst == nil || dst.IsUnspecified() {
+		return
+	}
+	r, err := routing.Lookup(net.ParseIP(si.LocalAddr.Host), dst)
+	switch {
+	case r == nil:
+		fmt.Printf("route:(unreachable)\t")
+	case err != nil:
+		fmt.Printf("route:(%s)\t", r.TypeString())
+	case r.Type == unix.RTN_LOCAL:
+		fmt.Printf("route:(local)\t")
+	case r.Gateway != nil:
+		fmt.Printf("route:(dev %s,via %s)\t", r.OutIf, r.Gateway)
+	default:
+		fmt.Printf("route:(dev %s)\t", r.OutIf)
+	}
+}
+
+// cgroupMatch applies the --container and --unit filters.
+func cgroupMatch(si *psss.SocketInfo) bool {
+	if len(*flagContainer) == 0 && len(*flagUnit) == 0 {
+		return true
+	}
+	if si.Cgroup == nil {
+		return false
+	}
+	if len(*flagContainer) > 0 && !si.Cgroup.MatchContainer(*flagContainer) {
+		return false
+	}
+	if len(*flagUnit) > 0 && !si.Cgroup.MatchUnit(*flagUnit) {
+		return false
+	}
+	return true
+}
+
+// readInetSockets reads the TCP and UDP sockets of both address families in every state.
+func readInetSockets() map[uint32]psss.SocketInfo {
+	ssFilter := psss.SsFilter
+	psss.SsFilter = (1 << psss.SsMAX) - 1
+	defer func() {
+		psss.SsFilter = ssFilter
+	}()
+
+	all := make(map[uint32]psss.SocketInfo)
+	for _, protocal := range []int{psss.ProtocalTCP, psss.ProtocalUDP} {
+		for _, af := range []int{unix.AF_INET, unix.AF_INET6} {
+			sis, err := psss.GenericInetRead(protocal, af)
+			if err != nil {
+				continue
+			}
+			for inode, si := range sis {
+				all[inode] = si
+			}
+		}
+	}
+	return all
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/buck119br/psss/psss"
+)
+
+// Doctor ranks the unhealthy TCP connections, explaining each finding in plain words.
+func Doctor(args []string) {
+	th := psss.DefaultDoctorThresholds
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	flagTop := fs.Int("top", 20, "number of connections to show, 0 for all")
+	flagProcess := fs.Bool("p", true, "show the processes holding the connections")
+	fs.Float64Var(&th.RetransPercent, "retrans", th.RetransPercent, "percent of the segments sent retransmitted")
+	flagNotsent := fs.Uint("notsent", uint(th.NotsentBytes), "bytes written but not sent yet")
+	flagSendQ := fs.Uint("sendq", uint(th.SendQueueBytes), "bytes sent but not acknowledged yet")
+	fs.Float64Var(&th.LimitedPercent, "limited", th.LimitedPercent, "percent of the busy time limited by the receive window or the send buffer")
+	fs.Float64Var(&th.RTTFactor, "rtt", th.RTTFactor, "times the median RTT of the remote subnet")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\tss doctor [ OPTIONS ]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	th.NotsentBytes = uint32(*flagNotsent)
+	th.SendQueueBytes = uint32(*flagSendQ)
+
+	ds, err := psss.Doctor(th)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if len(ds) == 0 {
+		fmt.Println("no unhealthy TCP connection")
+		return
+	}
+	if *flagTop > 0 && len(ds) > *flagTop {
+		ds = ds[:*flagTop]
+	}
+	if *flagProcess {
+		if err = ds.SetUpProcs(); err != nil {
+			fmt.Println(err)
+		}
+	}
+	fmt.Printf("%-8s%-12s%-48s%-48s%s\n", "Score", "State", "Local", "Peer", "Process")
+	for _, d := range ds {
+		fmt.Printf("%-8.1f%-12s%-48s%-48s%s\n", d.Score, psss.Sstate[d.Socket.Status],
+			d.Socket.LocalAddr.String(), d.Socket.RemoteAddr.String(), d.ProcNames())
+		for _, f := range d.Findings {
+			fmt.Printf("\t%-16s%s\n", f.Kind, f.Explain)
+		}
+	}
+}
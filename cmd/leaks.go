@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/buck119br/psss/psss"
+)
+
+// Leaks samples the processes and reports those growing steadily, and those close to their fd limit.
+func Leaks(args []string) {
+	fs := flag.NewFlagSet("leaks", flag.ExitOnError)
+	flagSamples := fs.Int("n", 10, "number of samples")
+	flagInterval := fs.Duration("i", time.Second, "interval between samples")
+	flagRss := fs.Float64("rss", 0, "rss growth threshold in bytes per second")
+	flagFds := fs.Float64("fds", 0, "fd growth threshold per second")
+	flagThreads := fs.Float64("threads", 0, "thread growth threshold per second")
+	flagSockets := fs.Float64("sockets", 0, "socket growth threshold per second")
+	flagRatio := fs.Float64("ratio", 0.8, "report fd usage beyond this share of RLIMIT_NOFILE")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\tss leaks [ OPTIONS ]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	d := psss.NewLeakDetector(*flagSamples)
+	d.Thresholds = psss.LeakThresholds{Rss: *flagRss, Fds: *flagFds, Threads: *flagThreads, Sockets: *flagSockets}
+	for i := 0; i < *flagSamples; i++ {
+		if i > 0 {
+			time.Sleep(*flagInterval)
+		}
+		if err := d.Sample(); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	leaks := d.Leaks()
+	fmt.Printf("Growing steadily over %d samples: %d\n", *flagSamples, len(leaks))
+	fmt.Printf("\tPid\tName\t\tGrowing\t\tRss/h\tFds/h\tThreads/h\tSockets/h\n")
+	for _, t := range leaks {
+		fmt.Printf("\t%d\t%-16s%-16s%s\t%.1f\t%.1f\t\t%.1f\n", t.Proc.Stat.Pid, t.Proc.Stat.Name, strings.Join(t.Growing, ","),
+			psss.BwToStr(t.RssSlope*3600), t.FdsSlope*3600, t.ThreadsSlope*3600, t.SocketsSlope*3600)
+	}
+
+	fmt.Printf("Fd usage beyond %.0f%% of the soft limit or growing:\n", *flagRatio*100)
+	fmt.Printf("\tPid\tName\t\tOpen\tSoft\tUsage\tExhausted in\n")
+	for _, t := range d.Trends() {
+		u := t.FdUsage
+		if u == nil || (u.Ratio < *flagRatio && u.ExhaustedIn == 0) {
+			continue
+		}
+		exhausted := "-"
+		if u.ExhaustedIn > 0 {
+			exhausted = u.ExhaustedIn.Round(time.Second).String()
+		}
+		fmt.Printf("\t%d\t%-16s%d\t%d\t%.1f%%\t%s\n", t.Proc.Stat.Pid, t.Proc.Stat.Name, u.Open, u.Soft, u.Ratio*100, exhausted)
+	}
+}
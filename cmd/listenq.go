@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/buck119br/psss/psss"
+)
+
+// ListenQueue shows how full the accept queues of the listeners are, along with the connections dropped by listeners.
+func ListenQueue(args []string) {
+	fs := flag.NewFlagSet("listenq", flag.ExitOnError)
+	flagInterval := fs.Duration("i", time.Second, "interval over which ListenOverflows and ListenDrops are compared")
+	flagSaturated := fs.Bool("saturated", false, "show only the saturated listeners")
+	flagWarn := fs.Float64("warn", psss.ListenSaturationPercent, "percent of the backlog above which a listener is saturated")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\tss listenq [ OPTIONS ]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	psss.ListenSaturationPercent = *flagWarn
+
+	lr, err := psss.GetListenReport(*flagInterval)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	seconds := lr.Interval.Seconds()
+	fmt.Printf("somaxconn:%d ListenOverflows:%d(%.1f/s) ListenDrops:%d(%.1f/s)\n", lr.Somaxconn,
+		lr.Counters.Overflows, float64(lr.Counters.Overflows)/seconds, lr.Counters.Drops, float64(lr.Counters.Drops)/seconds)
+
+	listeners := lr.Listeners
+	if *flagSaturated {
+		listeners = listeners.Saturated()
+	}
+	fmt.Printf("%-48s%-8s%-10s%-8s%-32s%s\n", "Local", "Queue", "Backlog", "Fill%", "Process", "Note")
+	for _, l := range listeners {
+		notes := make([]string, 0, 2)
+		switch {
+		case l.Full():
+			notes = append(notes, "full")
+		case l.Saturated():
+			notes = append(notes, "saturated")
+		}
+		if l.CappedBySomaxconn(lr.Somaxconn) {
+			notes = append(notes, "capped by somaxconn")
+		}
+		if l.BacklogUnknown {
+			notes = append(notes, "backlog unknown")
+			fmt.Printf("%-48s%-8d%-10s%-8s%-32s%s\n", l.Addr.String(), l.Queue, "-", "-", l.ProcNames(), strings.Join(notes, ","))
+			continue
+		}
+		fmt.Printf("%-48s%-8d%-10d%-8.1f%-32s%s\n", l.Addr.String(), l.Queue, l.Backlog, l.Fill(), l.ProcNames(), strings.Join(notes, ","))
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/buck119br/psss/psss"
+)
+
+// Lsof lists the open files of the processes.
+func Lsof(args []string) {
+	fs := flag.NewFlagSet("lsof", flag.ExitOnError)
+	flagPid := fs.Int("p", 0, "list only the files of this process")
+	flagPath := fs.String("path", "", "list only the files under this path prefix")
+	flagPort := fs.Int("port", 0, "list only the TCP and UDP sockets with this local or remote port")
+	flagType := fs.String("t", "", "list only the files of this type (file, dir, pipe, socket, anon_inode, memfd, device) or subtype (tcp, unix, eventpoll...)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\tss lsof [ OPTIONS ]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var procs []*psss.ProcInfo
+	if *flagPid > 0 {
+		proc := psss.NewProcInfo()
+		proc.Stat.Pid = *flagPid
+		if err := proc.GetStat(); err != nil {
+			fmt.Println(err)
+			return
+		}
+		if err := proc.GetOwner(); err != nil {
+			fmt.Println(err)
+			return
+		}
+		if err := proc.GetOpenFiles(); err != nil {
+			fmt.Println(err)
+			return
+		}
+		procs = append(procs, proc)
+	} else {
+		var err error
+		if procs, err = psss.DefaultProcScanner.Scan(psss.ProcReadOpenFiles); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	if err := psss.AttachSocketProtocols(procs); err != nil {
+		fmt.Println(err)
+	}
+	sis := readInetSockets()
+	port := strconv.Itoa(*flagPort)
+
+	fmt.Printf("%-16s%-8s%-8s%-6s%-12s%-12s%-12s%s\n", "COMMAND", "PID", "UID", "FD", "TYPE", "SUBTYPE", "POS", "NAME")
+	for _, proc := range procs {
+		for _, of := range proc.OpenFiles {
+			if len(*flagPath) > 0 && !strings.HasPrefix(of.Path(), *flagPath) {
+				continue
+			}
+			if len(*flagType) > 0 && of.Kind != *flagType && of.Subtype != *flagType {
+				continue
+			}
+			name := of.Target
+			if of.Kind == psss.FdKindSocket {
+				if si, ok := sis[uint32(of.Inode)]; ok {
+					name = fmt.Sprintf("%s->%s (%s)", si.LocalAddr.String(), si.RemoteAddr.String(), psss.Sstate[si.Status])
+					if *flagPort > 0 && si.LocalAddr.Port != port && si.RemoteAddr.Port != port {
+						continue
+					}
+				} else if *flagPort > 0 {
+					continue
+				}
+			} else if *flagPort > 0 {
+				continue
+			}
+			fmt.Printf("%-16s%-8d%-8d%-6d%-12s%-12s%-12d%s\n", proc.Stat.Name, proc.Stat.Pid, proc.UID, of.Fd, of.Kind, of.Subtype, of.Pos, name)
+		}
+	}
+}
@@ -3,8 +3,8 @@ package main
 import (
 	"flag"
 	"fmt"
+	"os"
 
-	"github.com/buck119br/psss/probe"
 	"github.com/buck119br/psss/psss"
 	"golang.org/x/sys/unix"
 )
@@ -12,9 +12,37 @@ import (
 const (
 	version = "ss utility, 0.0.1"
 	usage   = "Usage:\tss [ OPTIONS ]\n" +
-		"\tss [ OPTIONS ] [ FILTER ]\n"
+		"\tss [ OPTIONS ] [ FILTER ]\n" +
+		"\tss pstree [ OPTIONS ] [ PID ]\n" +
+		"\tss procevents [ OPTIONS ]\n" +
+		"\tss stuck [ OPTIONS ]\n" +
+		"\tss leaks [ OPTIONS ]\n" +
+		"\tss lsof [ OPTIONS ]\n" +
+		"\tss wholistens [ OPTIONS ] PORT\n" +
+		"\tss whoopens PATH\n" +
+		"\tss whousesmount MOUNTPOINT\n" +
+		"\tss nstat [ OPTIONS ]\n" +
+		"\tss ports [ OPTIONS ]\n" +
+		"\tss listenq [ OPTIONS ]\n" +
+		"\tss doctor [ OPTIONS ]\n"
 )
 
+// subcommands take the arguments following their name and parse their own flags.
+var subcommands = map[string]func(args []string){
+	"pstree":       PsTree,
+	"procevents":   ProcEvents,
+	"stuck":        Stuck,
+	"leaks":        Leaks,
+	"lsof":         Lsof,
+	"wholistens":   WhoListens,
+	"whoopens":     WhoOpens,
+	"whousesmount": WhoUsesMount,
+	"nstat":        Nstat,
+	"ports":        Ports,
+	"listenq":      ListenQueue,
+	"doctor":       Doctor,
+}
+
 var (
 	flagHelp    = flag.Bool("h", false, "help message")               // ok
 	flagVersion = flag.Bool("v", false, "output version information") // ok
@@ -41,15 +69,29 @@ var (
 	flagRAW    = flag.Bool("w", false, "display only RAW sockets")          // ok
 	flagUnix   = flag.Bool("x", false, "display only Unix domain sockets")  // ok
 
+	flagContainer = flag.String("container", "", "display only sockets of the container with this ID or ID prefix")
+	flagUnit      = flag.String("unit", "", "display only sockets of this systemd unit or slice")
+	flagRoute     = flag.Bool("route", false, "show the egress interface and gateway toward the remote address")
+
 	newlineFlag bool
 
 	sis map[uint32]psss.SocketInfo
+
+	procIndex psss.ProcFdIndex // sockets to owning processes, read for -p, --container and --unit
+
+	routing *psss.RoutingTables // read for --route
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			run(os.Args[2:])
+			return
+		}
+	}
 	flag.Parse()
 	if flag.NFlag() == 0 || *flagHelp {
-		fmt.Println(usage)
+		fmt.Print(usage)
 		flag.PrintDefaults()
 		return
 	}
@@ -123,7 +165,22 @@ func main() {
 
 	if *flagProcess {
 		psss.FlagProcess = true
-		psss.GetProcInfo(probe.GConfig.Process.ProcNameSet, false)
+	}
+	if *flagProcess || len(*flagContainer) > 0 || len(*flagUnit) > 0 {
+		procs, _, err := psss.DefaultProcCache.Scan(psss.ProcReadFds | psss.ProcReadCgroup)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		procIndex = psss.NewProcFdIndex(procs)
+	}
+
+	if *flagRoute {
+		routing = psss.NewRoutingTables()
+		if err := routing.Get(); err != nil {
+			fmt.Println(err)
+			return
+		}
 	}
 
 	SocketShow()
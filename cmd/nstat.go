@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/buck119br/psss/psss"
+)
+
+// Nstat shows the kernel network counters which changed over an interval, like nstat.
+func Nstat(args []string) {
+	fs := flag.NewFlagSet("nstat", flag.ExitOnError)
+	flagInterval := fs.Duration("i", time.Second, "interval over which the counters are compared")
+	flagAbsolute := fs.Bool("a", false, "show the values since boot instead of their increase")
+	flagZero := fs.Bool("z", false, "show the counters which did not change too")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\tss nstat [ OPTIONS ]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	cur := psss.NewSnmpCounters()
+	if *flagAbsolute {
+		if err := cur.Get(); err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("%-40s%s\n", "#kernel", "Value")
+		for _, c := range cur.Sorted(*flagZero) {
+			fmt.Printf("%-40s%d\n", c.Proto+c.Name, c.Value)
+		}
+		return
+	}
+
+	prev := psss.NewSnmpCounters()
+	if err := prev.Get(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	start := time.Now()
+	time.Sleep(*flagInterval)
+	if err := cur.Get(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	elapsed := time.Since(start).Seconds()
+
+	fmt.Printf("%-40s%-16s%s\n", "#kernel", "Delta", "Rate/s")
+	for _, c := range cur.Sorted(true) {
+		delta := c.Value - prev.Value(c.Proto, c.Name)
+		if delta == 0 && !*flagZero {
+			continue
+		}
+		fmt.Printf("%-40s%-16d%.1f\n", c.Proto+c.Name, delta, float64(delta)/elapsed)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/buck119br/psss/psss"
+)
+
+// Ports shows how many ephemeral ports each local address uses toward each remote address and port.
+func Ports(args []string) {
+	fs := flag.NewFlagSet("ports", flag.ExitOnError)
+	flagTop := fs.Int("top", 20, "show the n most used tuples, 0 for all")
+	flagWarn := fs.Float64("warn", psss.PortExhaustionPercent, "percent of the range above which a tuple is flagged")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\tss ports [ OPTIONS ]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	psss.PortExhaustionPercent = *flagWarn
+
+	lpr, pus, err := psss.GetPortUsages()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("ephemeral ports:%d-%d reserved:%d available:%d\n", lpr.Low, lpr.High, len(lpr.Reserved), lpr.Size())
+	if *flagTop > 0 && len(pus) > *flagTop {
+		pus = pus[:*flagTop]
+	}
+	fmt.Printf("%-40s%-48s%-8s%-10s%-8s\n", "Local", "Remote", "InUse", "TimeWait", "Use%")
+	for _, pu := range pus {
+		mark := ""
+		if pu.NearExhaustion() {
+			mark = "near exhaustion"
+		}
+		fmt.Printf("%-40s%-48s%-8d%-10d%-8.1f%s\n", pu.LocalHost, pu.RemoteAddr.String(), pu.InUse, pu.TimeWait, pu.Percent(), mark)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/buck119br/psss/psss"
+)
+
+// ProcEvents logs process lifecycle events until interrupted.
+func ProcEvents(args []string) {
+	fs := flag.NewFlagSet("procevents", flag.ExitOnError)
+	flagInterval := fs.Duration("i", time.Second, "scanning interval when the proc connector is unavailable")
+	flagThreads := fs.Bool("T", false, "show thread creation and exit")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\tss procevents [ OPTIONS ]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		cancel()
+	}()
+
+	events, err := psss.WatchProcEvents(ctx, *flagInterval)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v, scanning every %v instead: short lived processes and exit codes are missed\n", err, *flagInterval)
+	}
+	for e := range events {
+		line := procEventString(e, *flagThreads)
+		if len(line) == 0 {
+			continue
+		}
+		fmt.Printf("%s %s\n", time.Now().Format("15:04:05.000"), line)
+	}
+}
+
+func procEventString(e psss.ProcConnEvent, threads bool) string {
+	switch e := e.(type) {
+	case *psss.ProcForkEvent:
+		if e.ChildPid != e.ChildTgid && !threads {
+			return ""
+		}
+		return fmt.Sprintf("fork\tppid=%d pid=%d tid=%d\t%s", e.ParentTgid, e.ChildTgid, e.ChildPid, procComm(e.ChildTgid))
+	case *psss.ProcExecEvent:
+		return fmt.Sprintf("exec\tpid=%d\t%s", e.Tgid, procCmdline(e.Tgid))
+	case *psss.ProcExitEvent:
+		if e.Pid != e.Tgid && !threads {
+			return ""
+		}
+		if e.Polled {
+			return fmt.Sprintf("exit\tpid=%d tid=%d", e.Tgid, e.Pid)
+		}
+		status := e.WaitStatus()
+		if status.Signaled() {
+			return fmt.Sprintf("exit\tpid=%d tid=%d signal=%v", e.Tgid, e.Pid, status.Signal())
+		}
+		return fmt.Sprintf("exit\tpid=%d tid=%d code=%d", e.Tgid, e.Pid, status.ExitStatus())
+	case *psss.ProcUIDEvent:
+		return fmt.Sprintf("uid\tpid=%d ruid=%d euid=%d", e.Tgid, e.Ruid, e.Euid)
+	case *psss.ProcGIDEvent:
+		return fmt.Sprintf("gid\tpid=%d rgid=%d egid=%d", e.Tgid, e.Rgid, e.Egid)
+	case *psss.ProcCommEvent:
+		return fmt.Sprintf("comm\tpid=%d tid=%d\t%s", e.Tgid, e.Pid, e.Comm)
+	}
+	return ""
+}
+
+// procComm and procCmdline describe a process still alive when its event is logged, or return an empty string.
+func procComm(pid int) string {
+	raw, err := ioutil.ReadFile(psss.ProcRoot + "/" + strconv.Itoa(pid) + "/comm")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+func procCmdline(pid int) string {
+	raw, err := ioutil.ReadFile(psss.ProcRoot + "/" + strconv.Itoa(pid) + "/cmdline")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.Replace(string(raw), "\x00", " ", -1))
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/buck119br/psss/psss"
+)
+
+func PsTree(args []string) {
+	fs := flag.NewFlagSet("pstree", flag.ExitOnError)
+	flagArgs := fs.Bool("a", false, "show command line arguments")
+	flagSockets := fs.Bool("s", false, "show socket count and listening addresses per process")
+	flagTotals := fs.Bool("t", false, "show subtree totals: processes, cpu ticks, rss, fds, sockets")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\tss pstree [ OPTIONS ] [ PID ]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var options uint32
+	if *flagSockets || *flagTotals {
+		options |= psss.ProcReadFds
+	}
+	procs, err := psss.DefaultProcScanner.Scan(options)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	tree := psss.NewProcessTree(procs)
+	if *flagSockets || *flagTotals {
+		tree.AttachSockets(readInetSockets())
+	}
+
+	roots := tree.Roots
+	if fs.NArg() > 0 {
+		pid, err := strconv.Atoi(fs.Arg(0))
+		if err != nil {
+			fmt.Printf("invalid pid:[%s]\n", fs.Arg(0))
+			return
+		}
+		if _, ok := tree.Procs[pid]; !ok {
+			fmt.Printf("no such process:[%d]\n", pid)
+			return
+		}
+		roots = []int{pid}
+	}
+
+	for _, root := range roots {
+		// last[d] tells whether the ancestor at depth d was the last of its siblings
+		last := make([]bool, 0)
+		tree.Walk(root, func(proc *psss.ProcInfo, depth int) {
+			last = append(last[:depth], isLastChild(tree, proc))
+			var prefix strings.Builder
+			for d := 1; d < depth; d++ {
+				if last[d] {
+					prefix.WriteString("  ")
+				} else {
+					prefix.WriteString("| ")
+				}
+			}
+			if depth > 0 {
+				if last[depth] {
+					prefix.WriteString("`-")
+				} else {
+					prefix.WriteString("|-")
+				}
+			}
+			fmt.Printf("%s%s(%d)", prefix.String(), proc.Stat.Name, proc.Stat.Pid)
+			if *flagArgs && len(proc.Cmdline) > 0 {
+				fmt.Printf(" %s", strings.TrimSpace(strings.Join(proc.Cmdline, " ")))
+			}
+			if *flagSockets {
+				PsTreeSocketPrint(tree.Sockets[proc.Stat.Pid])
+			}
+			if *flagTotals {
+				pt := tree.SubtreeTotals(proc.Stat.Pid)
+				fmt.Printf(" [total:(procs:%d,cpu:%d,rss:%s,fds:%d,sockets:%d)]",
+					pt.Procs, pt.Utime+pt.Stime, psss.BwToStr(float64(pt.Rss*int64(psss.OSPageSize))), pt.Fds, pt.Sockets)
+			}
+			fmt.Printf("\n")
+		})
+	}
+}
+
+func isLastChild(tree *psss.ProcessTree, proc *psss.ProcInfo) bool {
+	siblings, ok := tree.Children[proc.Stat.Ppid]
+	if !ok || len(siblings) == 0 {
+		return true
+	}
+	return siblings[len(siblings)-1] == proc.Stat.Pid
+}
+
+func PsTreeSocketPrint(sis []psss.SocketInfo) {
+	if len(sis) == 0 {
+		return
+	}
+	listen := make([]string, 0)
+	for _, si := range sis {
+		if si.Status == psss.SsLISTEN {
+			listen = append(listen, si.LocalAddr.String())
+		}
+	}
+	fmt.Printf(" [sockets:%d", len(sis))
+	if len(listen) > 0 {
+		fmt.Printf(" listen:%s", strings.Join(listen, ","))
+	}
+	fmt.Printf("]")
+}
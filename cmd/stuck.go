@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/buck119br/psss/psss"
+)
+
+// Stuck samples the processes and reports the blocked, zombie and stopped ones.
+func Stuck(args []string) {
+	fs := flag.NewFlagSet("stuck", flag.ExitOnError)
+	flagSamples := fs.Int("n", 5, "consecutive samples a task must stay blocked or unreaped to be reported")
+	flagInterval := fs.Duration("i", time.Second, "interval between samples")
+	flagThreads := fs.Bool("T", false, "check every thread, not only the main thread of processes")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\tss stuck [ OPTIONS ]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	d := psss.NewStuckDetector(*flagSamples)
+	d.Threads = *flagThreads
+	var (
+		report *psss.StuckReport
+		err    error
+	)
+	for i := 0; i < *flagSamples; i++ {
+		if i > 0 {
+			time.Sleep(*flagInterval)
+		}
+		if report, err = d.Sample(); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	fmt.Printf("Uninterruptible sleep for %d samples: %d\n", *flagSamples, len(report.Uninterruptible))
+	for _, t := range report.Uninterruptible {
+		fmt.Printf("\tpid=%d tid=%d %s\twchan=%s\n", t.Proc.Stat.Pid, t.Stat.Pid, t.Stat.Name, t.Wchan)
+		for _, frame := range t.Stack {
+			fmt.Printf("\t\t%s\n", frame)
+		}
+	}
+	fmt.Printf("Zombies unreaped for %d samples: %d\n", *flagSamples, len(report.Zombies))
+	for _, z := range report.Zombies {
+		if z.Parent == nil {
+			fmt.Printf("\tpid=%d %s\tparent=%d exited\n", z.Proc.Stat.Pid, z.Proc.Stat.Name, z.Proc.Stat.Ppid)
+			continue
+		}
+		fmt.Printf("\tpid=%d %s\tparent=%d %s state=%s\n", z.Proc.Stat.Pid, z.Proc.Stat.Name,
+			z.Parent.Stat.Pid, z.Parent.Stat.Name, psss.ProcState[z.Parent.Stat.State])
+	}
+	fmt.Printf("Stopped: %d\n", len(report.Stopped))
+	for _, proc := range report.Stopped {
+		fmt.Printf("\tpid=%d %s\tstate=%s\n", proc.Stat.Pid, proc.Stat.Name, psss.ProcState[proc.Stat.State])
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/buck119br/psss/psss"
+)
+
+// WhoListens shows the processes listening on a port.
+func WhoListens(args []string) {
+	fs := flag.NewFlagSet("wholistens", flag.ExitOnError)
+	flagProto := fs.String("proto", "", "look up only tcp or udp sockets")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\tss wholistens [ OPTIONS ] PORT\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return
+	}
+	port, err := strconv.Atoi(fs.Arg(0))
+	if err != nil || port <= 0 || port > 65535 {
+		fmt.Printf("invalid port:[%s]\n", fs.Arg(0))
+		return
+	}
+	users, err := psss.WhoListens(port, *flagProto)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	showFileUsers(users)
+}
+
+// WhoOpens shows the processes using a file.
+func WhoOpens(args []string) {
+	fs := flag.NewFlagSet("whoopens", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\tss whoopens PATH\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return
+	}
+	users, err := psss.WhoOpens(fs.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	showFileUsers(users)
+}
+
+// WhoUsesMount shows the processes using a mounted filesystem, those keeping it busy on umount.
+func WhoUsesMount(args []string) {
+	fs := flag.NewFlagSet("whousesmount", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\tss whousesmount MOUNTPOINT\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return
+	}
+	users, err := psss.WhoUsesMount(fs.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	showFileUsers(users)
+}
+
+func showFileUsers(users []*psss.FileUser) {
+	fmt.Printf("%-16s%-8s%-12s%-8s%-6s%-24s%s\n", "COMMAND", "PID", "USER", "ACCESS", "FD", "FLAGS", "NAME")
+	for _, fu := range users {
+		fd, flags, name := "-", "-", fu.Path
+		if fu.Fd != nil {
+			fd = strconv.Itoa(fu.Fd.Fd)
+			flags = fu.Fd.FlagsString()
+		}
+		if fu.Socket != nil {
+			name = fmt.Sprintf("%s (%s)", fu.Socket.LocalAddr.String(), psss.Sstate[fu.Socket.Status])
+		}
+		fmt.Printf("%-16s%-8d%-12s%-8s%-6s%-24s%s\n", fu.Proc.Stat.Name, fu.Proc.Stat.Pid, fu.User, fu.Access, fd, flags, name)
+	}
+}
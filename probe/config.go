@@ -11,11 +11,29 @@ var GConfig *ProbeConfig
 type ProbeConfig struct {
 	SamplingInterval uint64
 
+	CPU struct {
+		Interrupts bool // read /proc/interrupts and /proc/softirqs for per-CPU interrupt rates
+	}
 	IO struct {
 		NIC struct {
 			Switch     bool
 			Interfaces []string
 		}
+		Snmp struct {
+			Switch bool // read the counters of /proc/net/snmp, snmp6 and netstat
+		}
+		Conntrack struct {
+			Switch      bool
+			Entries     bool    // also dump the table to aggregate it, costly on busy NAT gateways
+			WarnPercent float64 // log a warning when the table is fuller than this, 0 to never warn
+		}
+		Ports struct {
+			Switch bool // count the ephemeral ports used per local address and remote address and port
+		}
+		Listen struct {
+			Switch      bool    // read the accept queues of the listeners and the ListenOverflows and ListenDrops counters
+			WarnPercent float64 // log a warning with the owning processes when an accept queue is fuller than this, 0 to never warn
+		}
 	}
 	FileSystem struct {
 		MountInfo struct {
@@ -32,6 +50,13 @@ type ProbeConfig struct {
 		Switch      bool
 		ProcName    []string
 		ProcNameSet map[string]bool
+		Memory      bool // read smaps_rollup for per-service PSS accounting
+		Threads     bool // read per-thread CPU ticks, to find the hot threads of a process
+		Schedstat   bool // read schedstat for per-service run-queue delays
+	}
+	Cgroup struct {
+		Switch   bool
+		MaxDepth int // levels of the cgroup v2 hierarchy walked, 0 for all
 	}
 }
 
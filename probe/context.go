@@ -3,6 +3,7 @@ package probe
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
 	"runtime/debug"
 	"strconv"
 	"strings"
@@ -24,11 +25,37 @@ type ProbeContext struct {
 
 	Uptime     *psss.Uptime
 	SystemStat *psss.SystemStat
+	Interrupts *psss.Interrupts
+	Softirqs   *psss.Softirqs
+	LoadAvg    *psss.LoadAvg
+	Pressure   *psss.SystemPressure
+	VMStat     psss.VMStat
 	MemoryInfo *psss.MemoryInfo
 	NetDevs    psss.NetDevs
+	Links      psss.Links // metadata of the interfaces, as of the last sample
+	Softnet    psss.SoftnetStats
+	Snmp       psss.SnmpCounters
+	Conntrack  *psss.ConntrackUsage   // averaged over the samples
+	CtPeak     uint64                 // the highest Conntrack.Count of the samples
+	CtSummary  *psss.ConntrackSummary // as of the last sample, with IO.Conntrack.Entries
+	PortUsage  *psss.PortUsage        // the tuple using the most ephemeral ports, the peak of the samples
+	PortsNear  psss.PortUsages        // the tuples close to exhausting the ephemeral ports, as of the last sample
+	Listeners  psss.Listeners         // per address, the sample with the longest accept queue
+	ListenCnt  *psss.ListenCounters
 	MountInfo  map[string]*extMountInfo
 	FileInfo   []*psss.FileInfo
 	ProcInfo   map[string]map[int]*psss.ProcInfo
+	ProcMemory map[string]*psss.ProcMemory // per service, summed over its processes
+	Cgroups    psss.CgroupStats            // per systemd unit and container, see psss.CgroupStats.Services
+
+	ProcSchedstat map[string]*psss.ProcSchedstat // per service, summed over its processes
+	// per service, runqueue wait of all the threads of its processes, in percent of the sampling interval:
+	// 100 is one thread waiting all along, several threads waiting at once make it exceed 100
+	ProcRunDelay map[string]float64
+
+	CPUUtil        map[int]float64 // per CPU, percent of the sampling interval not idle nor waiting for I/O
+	CPUIntrRate    map[int]float64 // per CPU, hardware interrupts per second
+	CPUSoftirqRate map[int]float64 // per CPU, softirqs per second
 }
 
 func NewProbeContext() *ProbeContext {
@@ -60,6 +87,66 @@ func (pc *ProbeContext) GetSystemStat() error {
 	return pc.SystemStat.Get()
 }
 
+func (pc *ProbeContext) GetInterrupts() error {
+	defer func() {
+		if rcvErr := recover(); rcvErr != nil {
+			logger.Errorf("recovered from panic with error:[%v]", rcvErr)
+			debug.PrintStack()
+		}
+	}()
+
+	pc.Interrupts = new(psss.Interrupts)
+	if err := pc.Interrupts.Get(); err != nil {
+		return err
+	}
+	pc.Softirqs = new(psss.Softirqs)
+	return pc.Softirqs.Get()
+}
+
+func (pc *ProbeContext) GetLoadAvg() error {
+	defer func() {
+		if rcvErr := recover(); rcvErr != nil {
+			logger.Errorf("recovered from panic with error:[%v]", rcvErr)
+			debug.PrintStack()
+		}
+	}()
+
+	pc.LoadAvg = new(psss.LoadAvg)
+	return pc.LoadAvg.Get()
+}
+
+// GetPressure leaves Pressure nil on kernels without pressure stall information.
+func (pc *ProbeContext) GetPressure() error {
+	defer func() {
+		if rcvErr := recover(); rcvErr != nil {
+			logger.Errorf("recovered from panic with error:[%v]", rcvErr)
+			debug.PrintStack()
+		}
+	}()
+
+	sp := new(psss.SystemPressure)
+	if err := sp.Get(); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	pc.Pressure = sp
+	return nil
+}
+
+func (pc *ProbeContext) GetVMStat() error {
+	defer func() {
+		if rcvErr := recover(); rcvErr != nil {
+			logger.Errorf("recovered from panic with error:[%v]", rcvErr)
+			debug.PrintStack()
+		}
+	}()
+
+	pc.VMStat = psss.NewVMStat()
+	return pc.VMStat.Get()
+}
+
 func (pc *ProbeContext) GetMemoryInfo() error {
 	defer func() {
 		if rcvErr := recover(); rcvErr != nil {
@@ -69,7 +156,13 @@ func (pc *ProbeContext) GetMemoryInfo() error {
 	}()
 
 	pc.MemoryInfo = new(psss.MemoryInfo)
-	return pc.MemoryInfo.Get()
+	if err := pc.MemoryInfo.Get(); err != nil {
+		return err
+	}
+	for _, w := range pc.MemoryInfo.Warnings {
+		logger.Warnf("parse memory info warning:[%v]", w)
+	}
+	return nil
 }
 
 func (pc *ProbeContext) GetNetDevs() error {
@@ -81,7 +174,156 @@ func (pc *ProbeContext) GetNetDevs() error {
 	}()
 
 	pc.NetDevs = psss.NewNetDevs()
-	return pc.NetDevs.Get()
+	if err := pc.NetDevs.Get(); err != nil {
+		return err
+	}
+	for _, w := range pc.NetDevs.Warnings() {
+		logger.Warnf("parse net dev warning:[%v]", w)
+	}
+	// without a list of interfaces, lo is left out as it always was
+	if len(GConfig.IO.NIC.Interfaces) == 0 {
+		delete(pc.NetDevs, "lo")
+		return nil
+	}
+	wanted := make(map[string]bool, len(GConfig.IO.NIC.Interfaces))
+	for _, name := range GConfig.IO.NIC.Interfaces {
+		wanted[name] = true
+	}
+	for name := range pc.NetDevs {
+		if !wanted[name] {
+			delete(pc.NetDevs, name)
+		}
+	}
+	return nil
+}
+
+func (pc *ProbeContext) GetLinks() error {
+	defer func() {
+		if rcvErr := recover(); rcvErr != nil {
+			logger.Errorf("recovered from panic with error:[%v]", rcvErr)
+			debug.PrintStack()
+		}
+	}()
+
+	pc.Links = psss.NewLinks()
+	return pc.Links.Get()
+}
+
+func (pc *ProbeContext) GetSoftnet() error {
+	defer func() {
+		if rcvErr := recover(); rcvErr != nil {
+			logger.Errorf("recovered from panic with error:[%v]", rcvErr)
+			debug.PrintStack()
+		}
+	}()
+
+	pc.Softnet = psss.NewSoftnetStats()
+	return pc.Softnet.Get()
+}
+
+// GetConntrack leaves Conntrack nil while the nf_conntrack module is not loaded.
+func (pc *ProbeContext) GetConntrack() error {
+	defer func() {
+		if rcvErr := recover(); rcvErr != nil {
+			logger.Errorf("recovered from panic with error:[%v]", rcvErr)
+			debug.PrintStack()
+		}
+	}()
+
+	cu := new(psss.ConntrackUsage)
+	if err := cu.Get(); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	pc.Conntrack = cu
+	pc.CtPeak = cu.Count
+	if GConfig.IO.Conntrack.WarnPercent > 0 && cu.Percent() >= GConfig.IO.Conntrack.WarnPercent {
+		logger.Warnf("conntrack table usage:[%.1f%%] count:[%d] max:[%d]", cu.Percent(), cu.Count, cu.Max)
+	}
+	if !GConfig.IO.Conntrack.Entries {
+		return nil
+	}
+	ces := psss.NewConntrackEntries()
+	if err := ces.Get(); err != nil {
+		return err
+	}
+	pc.CtSummary = ces.Summary()
+	return nil
+}
+
+func (pc *ProbeContext) GetPortUsages() error {
+	defer func() {
+		if rcvErr := recover(); rcvErr != nil {
+			logger.Errorf("recovered from panic with error:[%v]", rcvErr)
+			debug.PrintStack()
+		}
+	}()
+
+	_, pus, err := psss.GetPortUsages()
+	if err != nil {
+		return err
+	}
+	pc.PortUsage = pus.Max()
+	pc.PortsNear = pus.NearExhaustion()
+	for _, pu := range pc.PortsNear {
+		logger.Warnf("ephemeral ports of:[%s] to:[%s] near exhaustion:[%d/%d] time-wait:[%d]",
+			pu.LocalHost, pu.RemoteAddr.String(), pu.InUse, pu.Size, pu.TimeWait)
+	}
+	return nil
+}
+
+func (pc *ProbeContext) GetListenCounters() error {
+	defer func() {
+		if rcvErr := recover(); rcvErr != nil {
+			logger.Errorf("recovered from panic with error:[%v]", rcvErr)
+			debug.PrintStack()
+		}
+	}()
+
+	pc.ListenCnt = new(psss.ListenCounters)
+	return pc.ListenCnt.Get()
+}
+
+// GetListeners only looks up the processes of the saturated listeners, as it scans every process.
+func (pc *ProbeContext) GetListeners() error {
+	defer func() {
+		if rcvErr := recover(); rcvErr != nil {
+			logger.Errorf("recovered from panic with error:[%v]", rcvErr)
+			debug.PrintStack()
+		}
+	}()
+
+	ls, err := psss.GetListeners()
+	if err != nil {
+		return err
+	}
+	pc.Listeners = ls
+	if GConfig.IO.Listen.WarnPercent <= 0 {
+		return nil
+	}
+	psss.ListenSaturationPercent = GConfig.IO.Listen.WarnPercent
+	saturated := ls.Saturated()
+	if err = saturated.SetUpProcs(); err != nil {
+		return err
+	}
+	for _, l := range saturated {
+		logger.Warnf("listener:[%s] of:[%s] accept queue:[%d/%d]", l.Addr.String(), l.ProcNames(), l.Queue, l.Backlog)
+	}
+	return nil
+}
+
+func (pc *ProbeContext) GetSnmp() error {
+	defer func() {
+		if rcvErr := recover(); rcvErr != nil {
+			logger.Errorf("recovered from panic with error:[%v]", rcvErr)
+			debug.PrintStack()
+		}
+	}()
+
+	pc.Snmp = psss.NewSnmpCounters()
+	return pc.Snmp.Get()
 }
 
 func (pc *ProbeContext) GetMountInfo() error {
@@ -97,10 +339,16 @@ func (pc *ProbeContext) GetMountInfo() error {
 	if err != nil {
 		return err
 	}
+	for _, w := range mis.Warnings() {
+		logger.Warnf("parse mount info warning:[%v]", w)
+	}
 	dss := psss.NewDiskStats()
 	if err = dss.Get(); err != nil {
 		return err
 	}
+	for _, w := range dss.Warnings() {
+		logger.Warnf("parse disk stat warning:[%v]", w)
+	}
 
 	pc.MountInfo = make(map[string]*extMountInfo)
 	var ok bool
@@ -156,6 +404,52 @@ func (pc *ProbeContext) GetFileInfo() error {
 	return nil
 }
 
+// procOptions are the process files read by both scans of a sample, to compute deltas.
+func procOptions() uint32 {
+	var options uint32
+	if GConfig.Process.Threads {
+		options |= psss.ProcReadThreads
+	}
+	if GConfig.Process.Schedstat {
+		options |= psss.ProcReadSchedstat
+	}
+	return options
+}
+
+func (pc *ProbeContext) GetProcInfo() {
+	defer func() {
+		if rcvErr := recover(); rcvErr != nil {
+			logger.Errorf("recovered from panic with error:[%v]", rcvErr)
+			debug.PrintStack()
+		}
+	}()
+
+	options := procOptions()
+	if GConfig.Process.Memory {
+		options |= psss.ProcReadMemory
+	}
+	pc.ProcInfo = psss.GetProcInfo(GConfig.Process.ProcNameSet, options)
+	if GConfig.Process.Memory {
+		pc.ProcMemory = psss.ServiceMemory(pc.ProcInfo)
+	}
+}
+
+func (pc *ProbeContext) GetCgroups() error {
+	defer func() {
+		if rcvErr := recover(); rcvErr != nil {
+			logger.Errorf("recovered from panic with error:[%v]", rcvErr)
+			debug.PrintStack()
+		}
+	}()
+
+	css := psss.NewCgroupStats()
+	if err := css.Get(GConfig.Cgroup.MaxDepth); err != nil {
+		return err
+	}
+	pc.Cgroups = css.Services()
+	return nil
+}
+
 func (pc *ProbeContext) Sample() error {
 	tick := time.NewTicker(time.Second)
 	defer func() {
@@ -171,19 +465,52 @@ func (pc *ProbeContext) Sample() error {
 	if err != nil {
 		logger.Errorf("get system stat error:[%v]", err)
 	}
+	if GConfig.CPU.Interrupts {
+		if err = prev.GetInterrupts(); err != nil {
+			logger.Errorf("get interrupts error:[%v]", err)
+		}
+	}
+	if err = prev.GetPressure(); err != nil {
+		logger.Errorf("get pressure error:[%v]", err)
+	}
+	if err = prev.GetVMStat(); err != nil {
+		logger.Errorf("get vmstat error:[%v]", err)
+	}
+	prevStatTime := time.Now()
+	var statElapsed time.Duration
 	if GConfig.Process.Switch {
-		prev.ProcInfo = psss.GetProcInfo(GConfig.Process.ProcNameSet, false)
+		prev.ProcInfo = psss.GetProcInfo(GConfig.Process.ProcNameSet, procOptions())
 	}
+	prevTime := time.Now()
+	var elapsed time.Duration
 	if GConfig.IO.NIC.Switch {
 		if err = prev.GetNetDevs(); err != nil {
 			logger.Errorf("get net devs error:[%v]", err)
 		}
+		if err = prev.GetSoftnet(); err != nil {
+			logger.Errorf("get softnet stat error:[%v]", err)
+		}
+	}
+	if GConfig.IO.Snmp.Switch {
+		if err = prev.GetSnmp(); err != nil {
+			logger.Errorf("get snmp error:[%v]", err)
+		}
+	}
+	if GConfig.IO.Listen.Switch {
+		if err = prev.GetListenCounters(); err != nil {
+			logger.Errorf("get listen counters error:[%v]", err)
+		}
 	}
 	if GConfig.FileSystem.MountInfo.Switch {
 		if err = prev.GetMountInfo(); err != nil {
 			logger.Errorf("get mount info error:[%v]", err)
 		}
 	}
+	if GConfig.Cgroup.Switch {
+		if err = prev.GetCgroups(); err != nil {
+			logger.Errorf("get cgroups error:[%v]", err)
+		}
+	}
 
 	select {
 	case <-tick.C:
@@ -193,6 +520,21 @@ func (pc *ProbeContext) Sample() error {
 		if err = pc.GetSystemStat(); err != nil {
 			logger.Errorf("get system stat error:[%v]", err)
 		}
+		if GConfig.CPU.Interrupts {
+			if err = pc.GetInterrupts(); err != nil {
+				logger.Errorf("get interrupts error:[%v]", err)
+			}
+		}
+		if err = pc.GetPressure(); err != nil {
+			logger.Errorf("get pressure error:[%v]", err)
+		}
+		if err = pc.GetVMStat(); err != nil {
+			logger.Errorf("get vmstat error:[%v]", err)
+		}
+		statElapsed = time.Since(prevStatTime)
+		if err = pc.GetLoadAvg(); err != nil {
+			logger.Errorf("get load average error:[%v]", err)
+		}
 		if err = pc.GetMemoryInfo(); err != nil {
 			logger.Errorf("get memory info error:[%v]", err)
 		}
@@ -200,6 +542,35 @@ func (pc *ProbeContext) Sample() error {
 			if err = pc.GetNetDevs(); err != nil {
 				logger.Errorf("get net devs error:[%v]", err)
 			}
+			if err = pc.GetLinks(); err != nil {
+				logger.Errorf("get links error:[%v]", err)
+			}
+			if err = pc.GetSoftnet(); err != nil {
+				logger.Errorf("get softnet stat error:[%v]", err)
+			}
+		}
+		if GConfig.IO.Snmp.Switch {
+			if err = pc.GetSnmp(); err != nil {
+				logger.Errorf("get snmp error:[%v]", err)
+			}
+		}
+		if GConfig.IO.Conntrack.Switch {
+			if err = pc.GetConntrack(); err != nil {
+				logger.Errorf("get conntrack error:[%v]", err)
+			}
+		}
+		if GConfig.IO.Ports.Switch {
+			if err = pc.GetPortUsages(); err != nil {
+				logger.Errorf("get port usages error:[%v]", err)
+			}
+		}
+		if GConfig.IO.Listen.Switch {
+			if err = pc.GetListenCounters(); err != nil {
+				logger.Errorf("get listen counters error:[%v]", err)
+			}
+			if err = pc.GetListeners(); err != nil {
+				logger.Errorf("get listeners error:[%v]", err)
+			}
 		}
 		if GConfig.FileSystem.MountInfo.Switch {
 			if err = pc.GetMountInfo(); err != nil {
@@ -207,7 +578,13 @@ func (pc *ProbeContext) Sample() error {
 			}
 		}
 		if GConfig.Process.Switch {
-			pc.ProcInfo = psss.GetProcInfo(GConfig.Process.ProcNameSet, false)
+			pc.GetProcInfo()
+		}
+		elapsed = time.Since(prevTime)
+		if GConfig.Cgroup.Switch {
+			if err = pc.GetCgroups(); err != nil {
+				logger.Errorf("get cgroups error:[%v]", err)
+			}
 		}
 
 		// the following modules are costly
@@ -229,6 +606,42 @@ func (pc *ProbeContext) Sample() error {
 	pc.SystemStat.CPUTotal.Guest -= prev.SystemStat.CPUTotal.Guest
 	pc.SystemStat.CPUTotal.GuestNice -= prev.SystemStat.CPUTotal.GuestNice
 	pc.SystemStat.CPUTotal.Total -= prev.SystemStat.CPUTotal.Total
+	pc.SystemStat.Intr -= prev.SystemStat.Intr
+	subVector(pc.SystemStat.IntrVector, prev.SystemStat.IntrVector)
+	pc.SystemStat.Softirq -= prev.SystemStat.Softirq
+	subVector(pc.SystemStat.SoftirqVector, prev.SystemStat.SoftirqVector)
+
+	pc.CPUUtil = make(map[int]float64, len(pc.SystemStat.CPUs))
+	for cpu, cj := range pc.SystemStat.CPUs {
+		prevcj, ok := prev.SystemStat.CPUs[cpu]
+		if !ok {
+			// onlined in between
+			delete(pc.SystemStat.CPUs, cpu)
+			continue
+		}
+		cj.Sub(prevcj)
+		pc.CPUUtil[cpu] = cj.Utilization()
+	}
+
+	if pc.Pressure != nil && prev.Pressure != nil {
+		pc.Pressure.Sub(prev.Pressure)
+	}
+	if pc.VMStat != nil && prev.VMStat != nil {
+		pc.VMStat.Sub(prev.VMStat)
+	}
+
+	if GConfig.CPU.Interrupts && pc.Interrupts != nil && prev.Interrupts != nil {
+		pc.Interrupts.Sub(prev.Interrupts)
+		pc.Softirqs.Sub(prev.Softirqs)
+		pc.CPUIntrRate = make(map[int]float64, len(pc.Interrupts.CPUs))
+		for cpu, v := range pc.Interrupts.PerCPU() {
+			pc.CPUIntrRate[cpu] = float64(v) / statElapsed.Seconds()
+		}
+		pc.CPUSoftirqRate = make(map[int]float64, len(pc.Softirqs.CPUs))
+		for cpu, v := range pc.Softirqs.PerCPU() {
+			pc.CPUSoftirqRate[cpu] = float64(v) / statElapsed.Seconds()
+		}
+	}
 
 	if GConfig.IO.NIC.Switch {
 		for _, nic := range pc.NetDevs {
@@ -253,6 +666,17 @@ func (pc *ProbeContext) Sample() error {
 			nic.TransmitCarrier -= prevnic.TransmitCarrier
 			nic.TransmitCompressed -= prevnic.TransmitCompressed
 		}
+		if pc.Softnet != nil && prev.Softnet != nil {
+			pc.Softnet.Sub(prev.Softnet)
+		}
+	}
+
+	if GConfig.IO.Snmp.Switch && pc.Snmp != nil && prev.Snmp != nil {
+		pc.Snmp.Sub(prev.Snmp)
+	}
+
+	if GConfig.IO.Listen.Switch && pc.ListenCnt != nil && prev.ListenCnt != nil {
+		pc.ListenCnt.Sub(prev.ListenCnt)
 	}
 
 	if GConfig.FileSystem.MountInfo.Switch {
@@ -296,10 +720,33 @@ func (pc *ProbeContext) Sample() error {
 				pi.Stat.Stime -= prevpi.Stat.Stime
 				pi.Stat.Cutime -= prevpi.Stat.Cutime
 				pi.Stat.Cstime -= prevpi.Stat.Cstime
+				// threads started since prev keep their whole CPU ticks, all spent in between
+				for tid, st := range pi.Threads {
+					prevst, ok := prevpi.Threads[tid]
+					if !ok {
+						continue
+					}
+					st.Utime -= prevst.Utime
+					st.Stime -= prevst.Stime
+				}
+				if pi.Schedstat != nil && prevpi.Schedstat != nil {
+					pi.Schedstat.Sub(prevpi.Schedstat)
+				}
+			}
+		}
+		if GConfig.Process.Schedstat {
+			pc.ProcSchedstat = psss.ServiceSchedstat(pc.ProcInfo)
+			pc.ProcRunDelay = make(map[string]float64, len(pc.ProcSchedstat))
+			for name, ps := range pc.ProcSchedstat {
+				pc.ProcRunDelay[name] = float64(ps.RunDelay) / float64(elapsed.Nanoseconds()) * 100
 			}
 		}
 	}
 
+	if GConfig.Cgroup.Switch {
+		pc.Cgroups.Delta(prev.Cgroups)
+	}
+
 	return nil
 }
 
@@ -323,6 +770,65 @@ func (pc *ProbeContext) FitSystemStat(new *ProbeContext) {
 	pc.SystemStat.CPUTotal.GuestNice += new.SystemStat.CPUTotal.GuestNice
 	pc.SystemStat.CPUTotal.Total += new.SystemStat.CPUTotal.Total
 	pc.SystemStat.Btime = new.SystemStat.Btime
+	pc.SystemStat.Intr += new.SystemStat.Intr
+	addVector(pc.SystemStat.IntrVector, new.SystemStat.IntrVector)
+	pc.SystemStat.Softirq += new.SystemStat.Softirq
+	addVector(pc.SystemStat.SoftirqVector, new.SystemStat.SoftirqVector)
+
+	for cpu, newcj := range new.SystemStat.CPUs {
+		cj, ok := pc.SystemStat.CPUs[cpu]
+		if !ok {
+			continue
+		}
+		cj.Add(newcj)
+		pc.CPUUtil[cpu] += new.CPUUtil[cpu]
+	}
+}
+
+// FitLoad sums the load averages, pressure and vmstat of new, to be averaged by Average.
+func (pc *ProbeContext) FitLoad(new *ProbeContext) {
+	defer func() {
+		if rcvErr := recover(); rcvErr != nil {
+			logger.Errorf("recovered from panic with error:[%v]", rcvErr)
+			debug.PrintStack()
+		}
+	}()
+
+	if pc.LoadAvg != nil && new.LoadAvg != nil {
+		pc.LoadAvg.Load1 += new.LoadAvg.Load1
+		pc.LoadAvg.Load5 += new.LoadAvg.Load5
+		pc.LoadAvg.Load15 += new.LoadAvg.Load15
+		pc.LoadAvg.Running += new.LoadAvg.Running
+		pc.LoadAvg.Total += new.LoadAvg.Total
+		pc.LoadAvg.LastPid = new.LoadAvg.LastPid
+	}
+	if pc.Pressure != nil && new.Pressure != nil {
+		pc.Pressure.Add(new.Pressure)
+	}
+	if pc.VMStat != nil && new.VMStat != nil {
+		pc.VMStat.Add(new.VMStat)
+	}
+}
+
+func (pc *ProbeContext) FitInterrupts(new *ProbeContext) {
+	defer func() {
+		if rcvErr := recover(); rcvErr != nil {
+			logger.Errorf("recovered from panic with error:[%v]", rcvErr)
+			debug.PrintStack()
+		}
+	}()
+
+	if pc.Interrupts == nil || new.Interrupts == nil {
+		return
+	}
+	pc.Interrupts.Add(new.Interrupts)
+	pc.Softirqs.Add(new.Softirqs)
+	for cpu := range pc.CPUIntrRate {
+		pc.CPUIntrRate[cpu] += new.CPUIntrRate[cpu]
+	}
+	for cpu := range pc.CPUSoftirqRate {
+		pc.CPUSoftirqRate[cpu] += new.CPUSoftirqRate[cpu]
+	}
 }
 
 func (pc *ProbeContext) FitMemoryInfo(new *ProbeContext) {
@@ -390,6 +896,11 @@ func (pc *ProbeContext) FitMemoryInfo(new *ProbeContext) {
 	pc.MemoryInfo.DirectMap2M += new.MemoryInfo.DirectMap2M
 	pc.MemoryInfo.DirectMap4M += new.MemoryInfo.DirectMap4M
 	pc.MemoryInfo.DirectMap1G += new.MemoryInfo.DirectMap1G
+	for key, v := range new.MemoryInfo.Extra {
+		if _, ok := pc.MemoryInfo.Extra[key]; ok {
+			pc.MemoryInfo.Extra[key] += v
+		}
+	}
 }
 
 func (pc *ProbeContext) FitNetDevs(new *ProbeContext) {
@@ -422,6 +933,79 @@ func (pc *ProbeContext) FitNetDevs(new *ProbeContext) {
 		nic.TransmitCarrier += newnic.TransmitCarrier
 		nic.TransmitCompressed += newnic.TransmitCompressed
 	}
+	if pc.Softnet != nil && new.Softnet != nil {
+		pc.Softnet.Add(new.Softnet)
+	}
+	if new.Links != nil {
+		pc.Links = new.Links
+	}
+}
+
+func (pc *ProbeContext) FitSnmp(new *ProbeContext) {
+	defer func() {
+		if rcvErr := recover(); rcvErr != nil {
+			logger.Errorf("recovered from panic with error:[%v]", rcvErr)
+			debug.PrintStack()
+		}
+	}()
+
+	if pc.Snmp == nil || new.Snmp == nil {
+		return
+	}
+	pc.Snmp.Add(new.Snmp)
+}
+
+func (pc *ProbeContext) FitConntrack(new *ProbeContext) {
+	defer func() {
+		if rcvErr := recover(); rcvErr != nil {
+			logger.Errorf("recovered from panic with error:[%v]", rcvErr)
+			debug.PrintStack()
+		}
+	}()
+
+	if new.CtSummary != nil {
+		pc.CtSummary = new.CtSummary
+	}
+	if new.Conntrack == nil {
+		return
+	}
+	if pc.Conntrack == nil {
+		pc.Conntrack, pc.CtPeak = new.Conntrack, new.CtPeak
+		return
+	}
+	pc.Conntrack.Count += new.Conntrack.Count
+	pc.Conntrack.Max = new.Conntrack.Max
+	pc.Conntrack.Buckets = new.Conntrack.Buckets
+	if new.CtPeak > pc.CtPeak {
+		pc.CtPeak = new.CtPeak
+	}
+}
+
+func (pc *ProbeContext) FitListen(new *ProbeContext) {
+	defer func() {
+		if rcvErr := recover(); rcvErr != nil {
+			logger.Errorf("recovered from panic with error:[%v]", rcvErr)
+			debug.PrintStack()
+		}
+	}()
+
+	if pc.ListenCnt != nil && new.ListenCnt != nil {
+		pc.ListenCnt.Add(new.ListenCnt)
+	}
+	peaks := make(map[string]int, len(pc.Listeners))
+	for i, l := range pc.Listeners {
+		peaks[l.Addr.String()] = i
+	}
+	for _, newl := range new.Listeners {
+		i, ok := peaks[newl.Addr.String()]
+		if !ok {
+			pc.Listeners = append(pc.Listeners, newl)
+			continue
+		}
+		if newl.Queue > pc.Listeners[i].Queue {
+			pc.Listeners[i] = newl
+		}
+	}
 }
 
 func (pc *ProbeContext) FitDiskStat(new *ProbeContext) {
@@ -480,10 +1064,70 @@ func (pc *ProbeContext) FitProcInfo(new *ProbeContext) {
 			pi.Stat.Stime += newpi.Stat.Stime
 			pi.Stat.Cutime += newpi.Stat.Cutime
 			pi.Stat.Cstime += newpi.Stat.Cstime
+			for tid, newst := range newpi.Threads {
+				st, ok := pi.Threads[tid]
+				if !ok {
+					continue
+				}
+				st.Utime += newst.Utime
+				st.Stime += newst.Stime
+			}
 		}
 	}
 }
 
+func (pc *ProbeContext) FitProcMemory(new *ProbeContext) {
+	defer func() {
+		if rcvErr := recover(); rcvErr != nil {
+			logger.Errorf("recovered from panic with error:[%v]", rcvErr)
+			debug.PrintStack()
+		}
+	}()
+
+	for name, newpm := range new.ProcMemory {
+		pm, ok := pc.ProcMemory[name]
+		if !ok {
+			continue
+		}
+		pm.Add(newpm)
+	}
+}
+
+func (pc *ProbeContext) FitProcSchedstat(new *ProbeContext) {
+	defer func() {
+		if rcvErr := recover(); rcvErr != nil {
+			logger.Errorf("recovered from panic with error:[%v]", rcvErr)
+			debug.PrintStack()
+		}
+	}()
+
+	for name, newps := range new.ProcSchedstat {
+		ps, ok := pc.ProcSchedstat[name]
+		if !ok {
+			continue
+		}
+		ps.Add(newps)
+		pc.ProcRunDelay[name] += new.ProcRunDelay[name]
+	}
+}
+
+func (pc *ProbeContext) FitCgroups(new *ProbeContext) {
+	defer func() {
+		if rcvErr := recover(); rcvErr != nil {
+			logger.Errorf("recovered from panic with error:[%v]", rcvErr)
+			debug.PrintStack()
+		}
+	}()
+
+	for name, newcs := range new.Cgroups {
+		cs, ok := pc.Cgroups[name]
+		if !ok {
+			continue
+		}
+		cs.Add(newcs)
+	}
+}
+
 func (pc *ProbeContext) Fit(new *ProbeContext) {
 	defer func() {
 		if rcvErr := recover(); rcvErr != nil {
@@ -496,22 +1140,68 @@ func (pc *ProbeContext) Fit(new *ProbeContext) {
 	if pc.SamplingCounter == 0 {
 		pc.Uptime = new.Uptime
 		pc.SystemStat = new.SystemStat
+		pc.Interrupts = new.Interrupts
+		pc.Softirqs = new.Softirqs
+		pc.LoadAvg = new.LoadAvg
+		pc.Pressure = new.Pressure
+		pc.VMStat = new.VMStat
+		pc.CPUUtil = new.CPUUtil
+		pc.CPUIntrRate = new.CPUIntrRate
+		pc.CPUSoftirqRate = new.CPUSoftirqRate
 		pc.MemoryInfo = new.MemoryInfo
 		pc.NetDevs = new.NetDevs
+		pc.Links = new.Links
+		pc.Softnet = new.Softnet
+		pc.Snmp = new.Snmp
+		pc.Conntrack = new.Conntrack
+		pc.CtPeak = new.CtPeak
+		pc.CtSummary = new.CtSummary
+		pc.PortUsage = new.PortUsage
+		pc.PortsNear = new.PortsNear
+		pc.Listeners = new.Listeners
+		pc.ListenCnt = new.ListenCnt
 		pc.MountInfo = new.MountInfo
 		pc.FileInfo = new.FileInfo
 		pc.ProcInfo = new.ProcInfo
+		pc.ProcMemory = new.ProcMemory
+		pc.Cgroups = new.Cgroups
+		pc.ProcSchedstat = new.ProcSchedstat
+		pc.ProcRunDelay = new.ProcRunDelay
 		return
 	}
 
 	pc.Uptime = new.Uptime
 	pc.FitSystemStat(new)
+	pc.FitLoad(new)
 	pc.FitMemoryInfo(new)
 
+	if GConfig.CPU.Interrupts {
+		pc.FitInterrupts(new)
+	}
+
 	if GConfig.IO.NIC.Switch {
 		pc.FitNetDevs(new)
 	}
 
+	if GConfig.IO.Snmp.Switch {
+		pc.FitSnmp(new)
+	}
+
+	if GConfig.IO.Conntrack.Switch {
+		pc.FitConntrack(new)
+	}
+
+	if GConfig.IO.Listen.Switch {
+		pc.FitListen(new)
+	}
+
+	if GConfig.IO.Ports.Switch {
+		if pc.PortUsage == nil || (new.PortUsage != nil && new.PortUsage.InUse > pc.PortUsage.InUse) {
+			pc.PortUsage = new.PortUsage
+		}
+		pc.PortsNear = new.PortsNear
+	}
+
 	if GConfig.FileSystem.MountInfo.Switch {
 		pc.FitDiskStat(new)
 	}
@@ -522,6 +1212,16 @@ func (pc *ProbeContext) Fit(new *ProbeContext) {
 
 	if GConfig.Process.Switch {
 		pc.FitProcInfo(new)
+		if GConfig.Process.Memory {
+			pc.FitProcMemory(new)
+		}
+		if GConfig.Process.Schedstat {
+			pc.FitProcSchedstat(new)
+		}
+	}
+
+	if GConfig.Cgroup.Switch {
+		pc.FitCgroups(new)
 	}
 }
 
@@ -541,6 +1241,39 @@ func (pc *ProbeContext) Average() {
 	pc.SystemStat.CPUTotal.Guest /= pc.SamplingCounter
 	pc.SystemStat.CPUTotal.GuestNice /= pc.SamplingCounter
 	pc.SystemStat.CPUTotal.Total /= pc.SamplingCounter
+	pc.SystemStat.Intr /= pc.SamplingCounter
+	divVector(pc.SystemStat.IntrVector, pc.SamplingCounter)
+	pc.SystemStat.Softirq /= pc.SamplingCounter
+	divVector(pc.SystemStat.SoftirqVector, pc.SamplingCounter)
+	for cpu, cj := range pc.SystemStat.CPUs {
+		cj.Div(pc.SamplingCounter)
+		pc.CPUUtil[cpu] /= float64(pc.SamplingCounter)
+	}
+
+	if pc.LoadAvg != nil {
+		pc.LoadAvg.Load1 /= float64(pc.SamplingCounter)
+		pc.LoadAvg.Load5 /= float64(pc.SamplingCounter)
+		pc.LoadAvg.Load15 /= float64(pc.SamplingCounter)
+		pc.LoadAvg.Running /= pc.SamplingCounter
+		pc.LoadAvg.Total /= pc.SamplingCounter
+	}
+	if pc.Pressure != nil {
+		pc.Pressure.Div(pc.SamplingCounter)
+	}
+	if pc.VMStat != nil {
+		pc.VMStat.Div(pc.SamplingCounter)
+	}
+
+	if GConfig.CPU.Interrupts && pc.Interrupts != nil {
+		pc.Interrupts.Div(pc.SamplingCounter)
+		pc.Softirqs.Div(pc.SamplingCounter)
+		for cpu := range pc.CPUIntrRate {
+			pc.CPUIntrRate[cpu] /= float64(pc.SamplingCounter)
+		}
+		for cpu := range pc.CPUSoftirqRate {
+			pc.CPUSoftirqRate[cpu] /= float64(pc.SamplingCounter)
+		}
+	}
 
 	pc.MemoryInfo.MemTotal /= pc.SamplingCounter
 	pc.MemoryInfo.MemFree /= pc.SamplingCounter
@@ -599,6 +1332,9 @@ func (pc *ProbeContext) Average() {
 	pc.MemoryInfo.DirectMap2M /= pc.SamplingCounter
 	pc.MemoryInfo.DirectMap4M /= pc.SamplingCounter
 	pc.MemoryInfo.DirectMap1G /= pc.SamplingCounter
+	for key := range pc.MemoryInfo.Extra {
+		pc.MemoryInfo.Extra[key] /= pc.SamplingCounter
+	}
 
 	if GConfig.IO.NIC.Switch {
 		for _, nic := range pc.NetDevs {
@@ -619,6 +1355,21 @@ func (pc *ProbeContext) Average() {
 			nic.TransmitCarrier /= pc.SamplingCounter
 			nic.TransmitCompressed /= pc.SamplingCounter
 		}
+		if pc.Softnet != nil {
+			pc.Softnet.Div(pc.SamplingCounter)
+		}
+	}
+
+	if GConfig.IO.Snmp.Switch && pc.Snmp != nil {
+		pc.Snmp.Div(pc.SamplingCounter)
+	}
+
+	if GConfig.IO.Listen.Switch && pc.ListenCnt != nil {
+		pc.ListenCnt.Div(pc.SamplingCounter)
+	}
+
+	if GConfig.IO.Conntrack.Switch && pc.Conntrack != nil {
+		pc.Conntrack.Count /= pc.SamplingCounter
 	}
 
 	if GConfig.FileSystem.MountInfo.Switch {
@@ -651,7 +1402,64 @@ func (pc *ProbeContext) Average() {
 				pi.Stat.Stime /= pc.SamplingCounter
 				pi.Stat.Cutime /= pc.SamplingCounter
 				pi.Stat.Cstime /= pc.SamplingCounter
+				for _, st := range pi.Threads {
+					st.Utime /= pc.SamplingCounter
+					st.Stime /= pc.SamplingCounter
+				}
 			}
 		}
+		if GConfig.Process.Memory {
+			for _, pm := range pc.ProcMemory {
+				pm.Rss /= pc.SamplingCounter
+				pm.Pss /= pc.SamplingCounter
+				pm.PssAnon /= pc.SamplingCounter
+				pm.PssFile /= pc.SamplingCounter
+				pm.PssShmem /= pc.SamplingCounter
+				pm.SharedClean /= pc.SamplingCounter
+				pm.SharedDirty /= pc.SamplingCounter
+				pm.PrivateClean /= pc.SamplingCounter
+				pm.PrivateDirty /= pc.SamplingCounter
+				pm.Swap /= pc.SamplingCounter
+				pm.SwapPss /= pc.SamplingCounter
+			}
+		}
+		if GConfig.Process.Schedstat {
+			for name, ps := range pc.ProcSchedstat {
+				ps.RunTime /= pc.SamplingCounter
+				ps.RunDelay /= pc.SamplingCounter
+				ps.Timeslices /= pc.SamplingCounter
+				pc.ProcRunDelay[name] /= float64(pc.SamplingCounter)
+			}
+		}
+	}
+
+	if GConfig.Cgroup.Switch {
+		for _, cs := range pc.Cgroups {
+			cs.Div(pc.SamplingCounter)
+		}
+	}
+}
+
+// subVector, addVector and divVector apply to the counters of the vectors of SystemStat,
+// whose length only changes across reboots.
+func subVector(v, prev []uint64) {
+	for i := range v {
+		if i < len(prev) {
+			v[i] -= prev[i]
+		}
+	}
+}
+
+func addVector(v, other []uint64) {
+	for i := range v {
+		if i < len(other) {
+			v[i] += other[i]
+		}
+	}
+}
+
+func divVector(v []uint64, n uint64) {
+	for i := range v {
+		v[i] /= n
 	}
 }
@@ -0,0 +1,91 @@
+// +build linux
+
+package psss
+
+import (
+	"sync"
+)
+
+var DefaultProcCache = NewProcCache(DefaultProcScanner)
+
+// ProcCache keeps the static data of every process between scans, keyed by ProcIdentity.
+// A known process only has its stat, its owner and the files selected by the ProcRead* options read again,
+// unless it ran execve since, see sameImage.
+type ProcCache struct {
+	mutex   sync.Mutex
+	scanner *ProcScanner
+	procs   map[int]*ProcInfo
+}
+
+func NewProcCache(scanner *ProcScanner) *ProcCache {
+	c := new(ProcCache)
+	c.scanner = scanner
+	c.procs = make(map[int]*ProcInfo)
+	return c
+}
+
+// Scan returns a fresh ProcInfo for every process, which the caller is free to modify,
+// along with the processes that appeared or exited since the previous scan.
+func (c *ProcCache) Scan(options uint32) (procs []*ProcInfo, events []ProcEvent, err error) {
+	return c.ScanMatching(options, nil)
+}
+
+// ScanMatching is Scan reading the files selected by options only for the processes match accepts,
+// given their stat, cmdline, exe and owner. A nil match accepts every process.
+func (c *ProcCache) ScanMatching(options uint32, match func(proc *ProcInfo) bool) (procs []*ProcInfo, events []ProcEvent, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	procs, err = c.scanner.scan(func(w *procWorker, pid int) *ProcInfo {
+		proc := NewProcInfo()
+		proc.Stat.Pid = pid
+		if err := w.readStat(proc); err != nil {
+			return nil
+		}
+		// the workers only read the cache, it is replaced once the scan is over.
+		// the owner is read on every scan, setuid changes it without execve
+		if cached, ok := c.procs[pid]; ok && sameImage(&cached.Stat, &proc.Stat) {
+			if err := w.readOwner(proc); err != nil {
+				return nil
+			}
+			proc.Cmdline = append([]string(nil), cached.Cmdline...)
+			proc.Exe = cached.Exe
+		} else if err := w.readStatic(proc); err != nil {
+			return nil
+		}
+		if match == nil || match(proc) {
+			w.readOptions(proc, options)
+		}
+		return proc
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	current := make(map[int]*ProcInfo, len(procs))
+	for _, proc := range procs {
+		snapshot := *proc
+		snapshot.Cmdline = append([]string(nil), proc.Cmdline...)
+		current[proc.Stat.Pid] = &snapshot
+		if prev, ok := c.procs[proc.Stat.Pid]; !ok || prev.Stat.Starttime != proc.Stat.Starttime {
+			events = append(events, ProcEvent{Type: ProcAppeared, Proc: &snapshot})
+		}
+	}
+	for pid, prev := range c.procs {
+		if proc, ok := current[pid]; !ok || proc.Stat.Starttime != prev.Stat.Starttime {
+			events = append(events, ProcEvent{Type: ProcExited, Proc: prev})
+		}
+	}
+	c.procs = current
+	return procs, events, nil
+}
+
+// sameImage tells whether both stats are of the same process still running the same program.
+// execve keeps the pid and the start time, but maps the new program and its arguments at new addresses,
+// and renames the process unless the new program has the same name.
+// The addresses read 0 for the processes this one may not ptrace, only the name is left for them.
+func sameImage(cached, st *ProcStat) bool {
+	return cached.Starttime == st.Starttime && cached.Name == st.Name &&
+		cached.Startcode == st.Startcode && cached.Endcode == st.Endcode && cached.Startstack == st.Startstack &&
+		cached.ArgStart == st.ArgStart && cached.ArgEnd == st.ArgEnd && cached.EnvStart == st.EnvStart && cached.EnvEnd == st.EnvEnd
+}
@@ -0,0 +1,193 @@
+// +build linux
+
+package psss
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	RuntimeDocker     = "docker"
+	RuntimeContainerd = "containerd"
+	RuntimeCRIO       = "cri-o"
+	RuntimePodman     = "podman"
+	RuntimeUnknown    = "unknown"
+)
+
+var (
+	containerIDRegExp = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	podUIDRegExp      = regexp.MustCompile(`pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})`)
+
+	// systemd scope prefixes of the container runtimes, conmon scopes of cri-o and podman excluded
+	containerScopePrefixes = []struct {
+		prefix  string
+		runtime string
+	}{
+		{"docker-", RuntimeDocker},
+		{"cri-containerd-", RuntimeContainerd},
+		{"crio-", RuntimeCRIO},
+		{"libpod-", RuntimePodman},
+	}
+
+	// cgroupfs parent directories of the container runtimes
+	containerParents = map[string]string{
+		"docker":        RuntimeDocker,
+		"libpod_parent": RuntimePodman,
+	}
+)
+
+// ProcCgroup is the cgroup membership of a process and the identity derived from the cgroup paths.
+// definition comes from http://man7.org/linux/man-pages/man7/cgroups.7.html
+type ProcCgroup struct {
+	Paths            map[string]string // controller list, "name=systemd" or "" for the v2 hierarchy, to path
+	Path             string            // the v2 path, or the v1 systemd path on hosts without a populated v2 hierarchy
+	ContainerID      string
+	ContainerRuntime string
+	PodUID           string // Kubernetes pod UID
+	Unit             string // systemd unit, such as nginx.service or docker-<id>.scope
+	Slice            string // innermost systemd slice
+}
+
+func NewProcCgroup() *ProcCgroup {
+	c := new(ProcCgroup)
+	c.Paths = make(map[string]string)
+	return c
+}
+
+func (c *ProcCgroup) Parse(raw []byte) error {
+	for _, line := range strings.Split(strings.TrimSpace(string(raw)), "\n") {
+		// hierarchy-ID:controller-list:cgroup-path
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			return fmt.Errorf("invalid line:[%s]", line)
+		}
+		if _, err := strconv.Atoi(fields[0]); err != nil {
+			return fmt.Errorf("parse hierarchy id:[%s] error:[%v]", fields[0], err)
+		}
+		c.Paths[fields[1]] = fields[2]
+	}
+
+	// the most specific path identifies the process; on hybrid hosts v2 is often left at the root
+	paths := make([]string, 0, len(c.Paths))
+	for _, key := range []string{"", "name=systemd"} {
+		if path, ok := c.Paths[key]; ok && path != "/" {
+			paths = append(paths, path)
+		}
+	}
+	// then the v1 controllers in the order of their names, so that the same file always gives the same result
+	keys := make([]string, 0, len(c.Paths))
+	for key := range c.Paths {
+		if key != "" && key != "name=systemd" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if path := c.Paths[key]; path != "/" {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		c.Path = "/"
+		return nil
+	}
+	c.Path = paths[0]
+	for _, path := range paths {
+		c.identify(path)
+	}
+	return nil
+}
+
+// identify fills the fields still unknown from the components of path.
+func (c *ProcCgroup) identify(path string) {
+	components := strings.Split(strings.Trim(path, "/"), "/")
+	for i := len(components) - 1; i >= 0; i-- {
+		component := components[i]
+		if len(c.PodUID) == 0 {
+			if match := podUIDRegExp.FindStringSubmatch(component); match != nil {
+				c.PodUID = strings.Replace(match[1], "_", "-", -1)
+			}
+		}
+		switch {
+		case strings.HasSuffix(component, ".slice"):
+			if len(c.Slice) == 0 {
+				c.Slice = component
+			}
+		case strings.HasSuffix(component, ".scope"), strings.HasSuffix(component, ".service"),
+			strings.HasSuffix(component, ".socket"), strings.HasSuffix(component, ".mount"):
+			if len(c.Unit) == 0 && len(c.Slice) == 0 {
+				c.Unit = component
+			}
+		case len(c.ContainerID) == 0 && containerIDRegExp.MatchString(component):
+			c.ContainerID = component
+			c.ContainerRuntime = RuntimeUnknown
+			if i > 0 {
+				if runtime, ok := containerParents[components[i-1]]; ok {
+					c.ContainerRuntime = runtime
+				}
+			}
+		}
+		if len(c.ContainerID) == 0 {
+			c.containerFromScope(strings.TrimSuffix(component, ".scope"))
+		}
+	}
+}
+
+// containerFromScope recognizes the systemd scope of a container, or its cgroupfs directory
+// when the runtime names it the same way, such as podman under libpod_parent.
+func (c *ProcCgroup) containerFromScope(scope string) {
+	for _, v := range containerScopePrefixes {
+		if !strings.HasPrefix(scope, v.prefix) {
+			continue
+		}
+		id := strings.TrimPrefix(scope, v.prefix)
+		if containerIDRegExp.MatchString(id) {
+			c.ContainerID = id
+			c.ContainerRuntime = v.runtime
+		}
+		return
+	}
+}
+
+// MatchContainer tells whether id is the container ID of the process or a prefix of it, as printed by docker ps.
+func (c *ProcCgroup) MatchContainer(id string) bool {
+	return len(id) > 0 && len(c.ContainerID) > 0 && strings.HasPrefix(c.ContainerID, id)
+}
+
+// MatchUnit tells whether the process runs in the systemd unit or slice name.
+// The unit type suffix may be omitted for services.
+func (c *ProcCgroup) MatchUnit(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	return c.Unit == name || c.Unit == name+".service" || c.Slice == name
+}
+
+// CgroupServiceName names the cgroup at path when it is the cgroup of a container or of a systemd unit
+// other than a slice: the runtime and the short container ID such as docker/0123456789ab, or the unit.
+// It returns an empty string for the other cgroups.
+func CgroupServiceName(path string) string {
+	c := NewProcCgroup()
+	c.identify(path)
+	last := path[strings.LastIndexByte(path, '/')+1:]
+	if len(c.ContainerID) > 0 && strings.Contains(last, c.ContainerID) {
+		return c.ContainerRuntime + "/" + c.ContainerID[:12]
+	}
+	if len(c.Unit) > 0 && c.Unit == last {
+		return c.Unit
+	}
+	return ""
+}
+
+func (p *ProcInfo) GetCgroup() error {
+	raw, err := p.readProcFile("cgroup")
+	if err != nil {
+		return err
+	}
+	p.Cgroup = NewProcCgroup()
+	return p.Cgroup.Parse(raw)
+}
@@ -0,0 +1,617 @@
+// +build linux
+
+package psss
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CgroupRoot is where the cgroup v2 hierarchy is looked up, either mounted there
+// directly or, on hybrid hosts, at its unified subdirectory.
+var CgroupRoot = "/sys/fs/cgroup"
+
+// CgroupV2Root returns the mount point of the cgroup v2 hierarchy.
+func CgroupV2Root() (string, error) {
+	for _, root := range []string{CgroupRoot, CgroupRoot + "/unified"} {
+		if _, err := os.Stat(root + "/cgroup.controllers"); err == nil {
+			return root, nil
+		}
+	}
+	return "", fmt.Errorf("cgroup v2 hierarchy not found under %s", CgroupRoot)
+}
+
+// PressureLine is one line of a PSI file, the share of wall time tasks were stalled.
+// definition comes from https://docs.kernel.org/accounting/psi.html
+type PressureLine struct {
+	Avg10  float64 // percent
+	Avg60  float64 // percent
+	Avg300 float64 // percent
+	Total  uint64  // cumulative stall time in microseconds
+}
+
+// Pressure holds the "some" and "full" lines of a PSI file.
+// Full is left zero for the CPU of kernels before 5.13.
+type Pressure struct {
+	Some PressureLine
+	Full PressureLine
+}
+
+func (p *Pressure) Parse(raw []byte) error {
+	for _, line := range strings.Split(strings.TrimSpace(string(raw)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 5 {
+			return fmt.Errorf("invalid line:[%s]", line)
+		}
+		var pl *PressureLine
+		switch fields[0] {
+		case "some":
+			pl = &p.Some
+		case "full":
+			pl = &p.Full
+		default:
+			continue
+		}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("invalid field:[%s]", field)
+			}
+			var err error
+			switch kv[0] {
+			case "avg10":
+				pl.Avg10, err = strconv.ParseFloat(kv[1], 64)
+			case "avg60":
+				pl.Avg60, err = strconv.ParseFloat(kv[1], 64)
+			case "avg300":
+				pl.Avg300, err = strconv.ParseFloat(kv[1], 64)
+			case "total":
+				pl.Total, err = strconv.ParseUint(kv[1], 10, 64)
+			}
+			if err != nil {
+				return fmt.Errorf("parse field:[%s] error:[%v]", field, err)
+			}
+		}
+	}
+	return nil
+}
+
+// CgroupCPUStat is cpu.stat, all values in microseconds but the period counters.
+type CgroupCPUStat struct {
+	UsageUsec     uint64
+	UserUsec      uint64
+	SystemUsec    uint64
+	NrPeriods     uint64 // enforcement periods elapsed, with cpu.max set
+	NrThrottled   uint64 // periods the cgroup was throttled in
+	ThrottledUsec uint64
+}
+
+// CgroupMemoryStat is the part of memory.stat accounting the usage, all values in bytes but the fault counters.
+type CgroupMemoryStat struct {
+	Anon          uint64
+	File          uint64
+	KernelStack   uint64
+	Slab          uint64
+	Sock          uint64
+	Shmem         uint64
+	FileMapped    uint64
+	FileDirty     uint64
+	FileWriteback uint64
+	ActiveAnon    uint64
+	InactiveAnon  uint64
+	ActiveFile    uint64
+	InactiveFile  uint64
+	Unevictable   uint64
+	Pgfault       uint64
+	Pgmajfault    uint64
+}
+
+// CgroupMemoryEvents is memory.events, the number of times each boundary was hit.
+type CgroupMemoryEvents struct {
+	Low     uint64
+	High    uint64
+	Max     uint64
+	Oom     uint64
+	OomKill uint64
+}
+
+// CgroupIOStat is one device line of io.stat.
+type CgroupIOStat struct {
+	Major  uint32
+	Minor  uint32
+	Rbytes uint64
+	Wbytes uint64
+	Rios   uint64
+	Wios   uint64
+	Dbytes uint64
+	Dios   uint64
+}
+
+// CgroupStat gathers the resource files of a cgroup, those of controllers not enabled for it are left nil.
+// definition comes from https://docs.kernel.org/admin-guide/cgroup-v2.html
+type CgroupStat struct {
+	Path           string // relative to the hierarchy root, as in /proc/[pid]/cgroup
+	CPU            *CgroupCPUStat
+	MemoryCurrent  uint64 // bytes
+	Memory         *CgroupMemoryStat
+	MemoryEvents   *CgroupMemoryEvents
+	IO             map[string]*CgroupIOStat // keyed by "major:minor"
+	PidsCurrent    uint64
+	CPUPressure    *Pressure
+	MemoryPressure *Pressure
+	IOPressure     *Pressure
+}
+
+// parseFlatKeyed parses the "key value" lines of cgroup files such as cpu.stat.
+func parseFlatKeyed(raw []byte, fn func(key string, v uint64)) error {
+	for _, line := range bytes.Split(bytes.TrimSpace(raw), []byte("\n")) {
+		fields := bytes.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return fmt.Errorf("invalid line:[%s]", line)
+		}
+		v, err := strconv.ParseUint(string(fields[1]), 10, 64)
+		if err != nil {
+			return fmt.Errorf("parse field:[%s] error:[%v]", fields[0], err)
+		}
+		fn(string(fields[0]), v)
+	}
+	return nil
+}
+
+func (cs *CgroupCPUStat) Parse(raw []byte) error {
+	return parseFlatKeyed(raw, func(key string, v uint64) {
+		switch key {
+		case "usage_usec":
+			cs.UsageUsec = v
+		case "user_usec":
+			cs.UserUsec = v
+		case "system_usec":
+			cs.SystemUsec = v
+		case "nr_periods":
+			cs.NrPeriods = v
+		case "nr_throttled":
+			cs.NrThrottled = v
+		case "throttled_usec":
+			cs.ThrottledUsec = v
+		}
+	})
+}
+
+func (ms *CgroupMemoryStat) Parse(raw []byte) error {
+	return parseFlatKeyed(raw, func(key string, v uint64) {
+		switch key {
+		case "anon":
+			ms.Anon = v
+		case "file":
+			ms.File = v
+		case "kernel_stack":
+			ms.KernelStack = v
+		case "slab":
+			ms.Slab = v
+		case "sock":
+			ms.Sock = v
+		case "shmem":
+			ms.Shmem = v
+		case "file_mapped":
+			ms.FileMapped = v
+		case "file_dirty":
+			ms.FileDirty = v
+		case "file_writeback":
+			ms.FileWriteback = v
+		case "active_anon":
+			ms.ActiveAnon = v
+		case "inactive_anon":
+			ms.InactiveAnon = v
+		case "active_file":
+			ms.ActiveFile = v
+		case "inactive_file":
+			ms.InactiveFile = v
+		case "unevictable":
+			ms.Unevictable = v
+		case "pgfault":
+			ms.Pgfault = v
+		case "pgmajfault":
+			ms.Pgmajfault = v
+		}
+	})
+}
+
+func (me *CgroupMemoryEvents) Parse(raw []byte) error {
+	return parseFlatKeyed(raw, func(key string, v uint64) {
+		switch key {
+		case "low":
+			me.Low = v
+		case "high":
+			me.High = v
+		case "max":
+			me.Max = v
+		case "oom":
+			me.Oom = v
+		case "oom_kill":
+			me.OomKill = v
+		}
+	})
+}
+
+// Parse reads a line such as "8:0 rbytes=1 wbytes=2 rios=3 wios=4 dbytes=0 dios=0".
+func (is *CgroupIOStat) Parse(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return fmt.Errorf("invalid line:[%s]", line)
+	}
+	if _, err := fmt.Sscanf(fields[0], "%d:%d", &is.Major, &is.Minor); err != nil {
+		return fmt.Errorf("parse device:[%s] error:[%v]", fields[0], err)
+	}
+	for _, field := range fields[1:] {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid field:[%s]", field)
+		}
+		v, err := strconv.ParseUint(kv[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("parse field:[%s] error:[%v]", field, err)
+		}
+		switch kv[0] {
+		case "rbytes":
+			is.Rbytes = v
+		case "wbytes":
+			is.Wbytes = v
+		case "rios":
+			is.Rios = v
+		case "wios":
+			is.Wios = v
+		case "dbytes":
+			is.Dbytes = v
+		case "dios":
+			is.Dios = v
+		}
+	}
+	return nil
+}
+
+func parseIOStat(raw []byte) (map[string]*CgroupIOStat, error) {
+	ios := make(map[string]*CgroupIOStat)
+	for _, line := range strings.Split(strings.TrimSpace(string(raw)), "\n") {
+		if len(line) == 0 {
+			continue
+		}
+		is := new(CgroupIOStat)
+		if err := is.Parse(line); err != nil {
+			return nil, err
+		}
+		ios[fmt.Sprintf("%d:%d", is.Major, is.Minor)] = is
+	}
+	return ios, nil
+}
+
+func parseSingleValue(raw []byte) (uint64, error) {
+	return strconv.ParseUint(string(bytes.TrimSpace(raw)), 10, 64)
+}
+
+// ReadCgroupStat reads the resource files of the cgroup at path, relative to the v2 hierarchy root.
+func ReadCgroupStat(path string) (*CgroupStat, error) {
+	root, err := CgroupV2Root()
+	if err != nil {
+		return nil, err
+	}
+	return readCgroupStat(root, path)
+}
+
+func readCgroupStat(root, path string) (*CgroupStat, error) {
+	dir := filepath.Join(root, path)
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	cs := new(CgroupStat)
+	cs.Path = path
+	// a file is missing when its controller is not enabled for the cgroup, or for the root cgroup
+	read := func(name string, parse func(raw []byte) error) error {
+		raw, err := ioutil.ReadFile(dir + "/" + name)
+		if err != nil {
+			return nil
+		}
+		if err = parse(raw); err != nil {
+			return fmt.Errorf("parse %s/%s error:[%v]", dir, name, err)
+		}
+		return nil
+	}
+	for _, f := range []struct {
+		name  string
+		parse func(raw []byte) error
+	}{
+		{"cpu.stat", func(raw []byte) error { cs.CPU = new(CgroupCPUStat); return cs.CPU.Parse(raw) }},
+		{"memory.current", func(raw []byte) (err error) { cs.MemoryCurrent, err = parseSingleValue(raw); return }},
+		{"memory.stat", func(raw []byte) error { cs.Memory = new(CgroupMemoryStat); return cs.Memory.Parse(raw) }},
+		{"memory.events", func(raw []byte) error { cs.MemoryEvents = new(CgroupMemoryEvents); return cs.MemoryEvents.Parse(raw) }},
+		{"io.stat", func(raw []byte) (err error) { cs.IO, err = parseIOStat(raw); return }},
+		{"pids.current", func(raw []byte) (err error) { cs.PidsCurrent, err = parseSingleValue(raw); return }},
+		{"cpu.pressure", func(raw []byte) error { cs.CPUPressure = new(Pressure); return cs.CPUPressure.Parse(raw) }},
+		{"memory.pressure", func(raw []byte) error { cs.MemoryPressure = new(Pressure); return cs.MemoryPressure.Parse(raw) }},
+		{"io.pressure", func(raw []byte) error { cs.IOPressure = new(Pressure); return cs.IOPressure.Parse(raw) }},
+	} {
+		if err := read(f.name, f.parse); err != nil {
+			return nil, err
+		}
+	}
+	return cs, nil
+}
+
+// Sub turns the cumulative counters of cs into the increase since prev, gauges are kept as read.
+// A unit restarting recreates its cgroup at the same path with counters from 0, see subCounter.
+func (cs *CgroupStat) Sub(prev *CgroupStat) {
+	if cs.CPU != nil && prev.CPU != nil {
+		subCounter(&cs.CPU.UsageUsec, prev.CPU.UsageUsec)
+		subCounter(&cs.CPU.UserUsec, prev.CPU.UserUsec)
+		subCounter(&cs.CPU.SystemUsec, prev.CPU.SystemUsec)
+		subCounter(&cs.CPU.NrPeriods, prev.CPU.NrPeriods)
+		subCounter(&cs.CPU.NrThrottled, prev.CPU.NrThrottled)
+		subCounter(&cs.CPU.ThrottledUsec, prev.CPU.ThrottledUsec)
+	}
+	if cs.Memory != nil && prev.Memory != nil {
+		subCounter(&cs.Memory.Pgfault, prev.Memory.Pgfault)
+		subCounter(&cs.Memory.Pgmajfault, prev.Memory.Pgmajfault)
+	}
+	if cs.MemoryEvents != nil && prev.MemoryEvents != nil {
+		subCounter(&cs.MemoryEvents.Low, prev.MemoryEvents.Low)
+		subCounter(&cs.MemoryEvents.High, prev.MemoryEvents.High)
+		subCounter(&cs.MemoryEvents.Max, prev.MemoryEvents.Max)
+		subCounter(&cs.MemoryEvents.Oom, prev.MemoryEvents.Oom)
+		subCounter(&cs.MemoryEvents.OomKill, prev.MemoryEvents.OomKill)
+	}
+	for dev, is := range cs.IO {
+		previs, ok := prev.IO[dev]
+		if !ok {
+			continue
+		}
+		subCounter(&is.Rbytes, previs.Rbytes)
+		subCounter(&is.Wbytes, previs.Wbytes)
+		subCounter(&is.Rios, previs.Rios)
+		subCounter(&is.Wios, previs.Wios)
+		subCounter(&is.Dbytes, previs.Dbytes)
+		subCounter(&is.Dios, previs.Dios)
+	}
+	subPressure(cs.CPUPressure, prev.CPUPressure)
+	subPressure(cs.MemoryPressure, prev.MemoryPressure)
+	subPressure(cs.IOPressure, prev.IOPressure)
+}
+
+// CgroupStats is a walk of the cgroup v2 hierarchy keyed by cgroup path, or by service name after Services.
+type CgroupStats map[string]*CgroupStat
+
+func NewCgroupStats() CgroupStats {
+	return make(CgroupStats)
+}
+
+// Get walks the hierarchy down to maxDepth levels below the root, 0 meaning no limit.
+// Cgroups removed during the walk are skipped.
+func (css CgroupStats) Get(maxDepth int) error {
+	root, err := CgroupV2Root()
+	if err != nil {
+		return err
+	}
+	return filepath.Walk(root, func(dir string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		path := "/" + strings.TrimPrefix(strings.TrimPrefix(dir, root), "/")
+		if maxDepth > 0 && path != "/" && strings.Count(path, "/") > maxDepth {
+			return filepath.SkipDir
+		}
+		cs, err := readCgroupStat(root, path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		css[path] = cs
+		return nil
+	})
+}
+
+// Delta turns every cgroup into its increase since prev, cgroups created since then are dropped.
+// Both must be keyed the same way.
+func (css CgroupStats) Delta(prev CgroupStats) {
+	for path, cs := range css {
+		prevcs, ok := prev[path]
+		if !ok {
+			delete(css, path)
+			continue
+		}
+		cs.Sub(prevcs)
+	}
+}
+
+func (pl *PressureLine) add(other *PressureLine) {
+	pl.Avg10 += other.Avg10
+	pl.Avg60 += other.Avg60
+	pl.Avg300 += other.Avg300
+	pl.Total += other.Total
+}
+
+func (pl *PressureLine) div(n uint64) {
+	pl.Avg10 /= float64(n)
+	pl.Avg60 /= float64(n)
+	pl.Avg300 /= float64(n)
+	pl.Total /= n
+}
+
+// subCounter turns the cumulative counter *cur into its increase since prev. A counter found below prev was reset
+// in between, such as by the cgroup being recreated, and all of its current value was counted since.
+func subCounter(cur *uint64, prev uint64) {
+	if *cur >= prev {
+		*cur -= prev
+	}
+}
+
+// subPressure turns the stall totals of p into their increase since prev, the averages are kept.
+func subPressure(p, prev *Pressure) {
+	if p == nil || prev == nil {
+		return
+	}
+	subCounter(&p.Some.Total, prev.Some.Total)
+	subCounter(&p.Full.Total, prev.Full.Total)
+}
+
+func addPressure(p, other *Pressure) {
+	if p == nil || other == nil {
+		return
+	}
+	p.Some.add(&other.Some)
+	p.Full.add(&other.Full)
+}
+
+func divPressure(p *Pressure, n uint64) {
+	if p == nil {
+		return
+	}
+	p.Some.div(n)
+	p.Full.div(n)
+}
+
+// Add sums every value of other into cs, so that samples can be averaged with Div.
+func (cs *CgroupStat) Add(other *CgroupStat) {
+	if cs.CPU != nil && other.CPU != nil {
+		cs.CPU.UsageUsec += other.CPU.UsageUsec
+		cs.CPU.UserUsec += other.CPU.UserUsec
+		cs.CPU.SystemUsec += other.CPU.SystemUsec
+		cs.CPU.NrPeriods += other.CPU.NrPeriods
+		cs.CPU.NrThrottled += other.CPU.NrThrottled
+		cs.CPU.ThrottledUsec += other.CPU.ThrottledUsec
+	}
+	cs.MemoryCurrent += other.MemoryCurrent
+	if cs.Memory != nil && other.Memory != nil {
+		cs.Memory.Anon += other.Memory.Anon
+		cs.Memory.File += other.Memory.File
+		cs.Memory.KernelStack += other.Memory.KernelStack
+		cs.Memory.Slab += other.Memory.Slab
+		cs.Memory.Sock += other.Memory.Sock
+		cs.Memory.Shmem += other.Memory.Shmem
+		cs.Memory.FileMapped += other.Memory.FileMapped
+		cs.Memory.FileDirty += other.Memory.FileDirty
+		cs.Memory.FileWriteback += other.Memory.FileWriteback
+		cs.Memory.ActiveAnon += other.Memory.ActiveAnon
+		cs.Memory.InactiveAnon += other.Memory.InactiveAnon
+		cs.Memory.ActiveFile += other.Memory.ActiveFile
+		cs.Memory.InactiveFile += other.Memory.InactiveFile
+		cs.Memory.Unevictable += other.Memory.Unevictable
+		cs.Memory.Pgfault += other.Memory.Pgfault
+		cs.Memory.Pgmajfault += other.Memory.Pgmajfault
+	}
+	if cs.MemoryEvents != nil && other.MemoryEvents != nil {
+		cs.MemoryEvents.Low += other.MemoryEvents.Low
+		cs.MemoryEvents.High += other.MemoryEvents.High
+		cs.MemoryEvents.Max += other.MemoryEvents.Max
+		cs.MemoryEvents.Oom += other.MemoryEvents.Oom
+		cs.MemoryEvents.OomKill += other.MemoryEvents.OomKill
+	}
+	for dev, is := range cs.IO {
+		otheris, ok := other.IO[dev]
+		if !ok {
+			continue
+		}
+		is.Rbytes += otheris.Rbytes
+		is.Wbytes += otheris.Wbytes
+		is.Rios += otheris.Rios
+		is.Wios += otheris.Wios
+		is.Dbytes += otheris.Dbytes
+		is.Dios += otheris.Dios
+	}
+	cs.PidsCurrent += other.PidsCurrent
+	addPressure(cs.CPUPressure, other.CPUPressure)
+	addPressure(cs.MemoryPressure, other.MemoryPressure)
+	addPressure(cs.IOPressure, other.IOPressure)
+}
+
+func (cs *CgroupStat) Div(n uint64) {
+	if n == 0 {
+		return
+	}
+	if cs.CPU != nil {
+		cs.CPU.UsageUsec /= n
+		cs.CPU.UserUsec /= n
+		cs.CPU.SystemUsec /= n
+		cs.CPU.NrPeriods /= n
+		cs.CPU.NrThrottled /= n
+		cs.CPU.ThrottledUsec /= n
+	}
+	cs.MemoryCurrent /= n
+	if cs.Memory != nil {
+		cs.Memory.Anon /= n
+		cs.Memory.File /= n
+		cs.Memory.KernelStack /= n
+		cs.Memory.Slab /= n
+		cs.Memory.Sock /= n
+		cs.Memory.Shmem /= n
+		cs.Memory.FileMapped /= n
+		cs.Memory.FileDirty /= n
+		cs.Memory.FileWriteback /= n
+		cs.Memory.ActiveAnon /= n
+		cs.Memory.InactiveAnon /= n
+		cs.Memory.ActiveFile /= n
+		cs.Memory.InactiveFile /= n
+		cs.Memory.Unevictable /= n
+		cs.Memory.Pgfault /= n
+		cs.Memory.Pgmajfault /= n
+	}
+	if cs.MemoryEvents != nil {
+		cs.MemoryEvents.Low /= n
+		cs.MemoryEvents.High /= n
+		cs.MemoryEvents.Max /= n
+		cs.MemoryEvents.Oom /= n
+		cs.MemoryEvents.OomKill /= n
+	}
+	for _, is := range cs.IO {
+		is.Rbytes /= n
+		is.Wbytes /= n
+		is.Rios /= n
+		is.Wios /= n
+		is.Dbytes /= n
+		is.Dios /= n
+	}
+	cs.PidsCurrent /= n
+	divPressure(cs.CPUPressure, n)
+	divPressure(cs.MemoryPressure, n)
+	divPressure(cs.IOPressure, n)
+}
+
+// Services keeps the cgroups of systemd units and containers, keyed by CgroupServiceName.
+// Their statistics already include those of their descendants. A service nested in another, such as
+// the units of a user manager, is named after the closest one as user@1000.service/dbus.service,
+// so that it does not take the place of the unit of the same name in system.slice.
+// Services still sharing a name are summed.
+func (css CgroupStats) Services() CgroupStats {
+	services := make(CgroupStats)
+	for path, cs := range css {
+		name := CgroupServiceName(path)
+		if len(name) == 0 {
+			continue
+		}
+		for parent := filepath.Dir(path); parent != "/" && parent != "."; parent = filepath.Dir(parent) {
+			if outer := CgroupServiceName(parent); len(outer) > 0 {
+				name = outer + "/" + name
+				break
+			}
+		}
+		if same, ok := services[name]; ok {
+			same.Add(cs)
+			continue
+		}
+		services[name] = cs
+	}
+	return services
+}
@@ -0,0 +1,350 @@
+// +build linux
+
+package psss
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// definition comes from Linux kernel /include/uapi/linux/connector.h and cn_proc.h
+const (
+	CN_IDX_PROC = 0x1
+	CN_VAL_PROC = 0x1
+
+	PROC_CN_MCAST_LISTEN = 1
+	PROC_CN_MCAST_IGNORE = 2
+
+	PROC_EVENT_NONE     = 0x00000000
+	PROC_EVENT_FORK     = 0x00000001
+	PROC_EVENT_EXEC     = 0x00000002
+	PROC_EVENT_UID      = 0x00000004
+	PROC_EVENT_GID      = 0x00000040
+	PROC_EVENT_SID      = 0x00000080
+	PROC_EVENT_PTRACE   = 0x00000100
+	PROC_EVENT_COMM     = 0x00000200
+	PROC_EVENT_COREDUMP = 0x40000000
+	PROC_EVENT_EXIT     = 0x80000000
+
+	SizeOfCnMsg           = 20
+	SizeOfProcEventHeader = 16
+	SizeOfProcCnRequest   = unix.SizeofNlMsghdr + SizeOfCnMsg + 4
+)
+
+var (
+	// ErrProcConnectorUnavailable is returned when the proc connector cannot be subscribed to,
+	// lacking CAP_NET_ADMIN or outside of the initial user and pid namespaces.
+	ErrProcConnectorUnavailable = fmt.Errorf("proc connector unavailable")
+
+	// ProcConnectorRecvTimeout bounds how long a cancelled subscription keeps its socket.
+	ProcConnectorRecvTimeout = time.Second
+)
+
+type CbID struct {
+	Idx uint32
+	Val uint32
+}
+
+type CnMsg struct {
+	ID    CbID
+	Seq   uint32
+	Ack   uint32
+	Len   uint16
+	Flags uint16
+}
+
+type ProcCnRequest struct {
+	Header unix.NlMsghdr
+	Msg    CnMsg
+	Op     uint32
+}
+
+// ProcConnHeader is common to all the events of the proc connector.
+type ProcConnHeader struct {
+	CPU       uint32
+	Timestamp uint64 // nanoseconds since boot
+	Polled    bool   // synthesized by WatchProcEvents from two scans, without CPU nor Timestamp
+}
+
+func (h *ProcConnHeader) Header() *ProcConnHeader {
+	return h
+}
+
+// ProcConnEvent is one of ProcForkEvent, ProcExecEvent, ProcExitEvent, ProcUIDEvent, ProcGIDEvent and ProcCommEvent.
+type ProcConnEvent interface {
+	Header() *ProcConnHeader
+}
+
+// ProcForkEvent reports a new process, or a new thread when ChildPid differs from ChildTgid.
+type ProcForkEvent struct {
+	ProcConnHeader
+	ParentPid  int
+	ParentTgid int
+	ChildPid   int
+	ChildTgid  int
+}
+
+type ProcExecEvent struct {
+	ProcConnHeader
+	Pid  int
+	Tgid int
+}
+
+type ProcExitEvent struct {
+	ProcConnHeader
+	Pid        int
+	Tgid       int
+	ExitCode   uint32 // wait status, see WaitStatus
+	ExitSignal uint32 // signal sent to the parent, SIGCHLD for processes
+}
+
+func (e *ProcExitEvent) WaitStatus() syscall.WaitStatus {
+	return syscall.WaitStatus(e.ExitCode)
+}
+
+type ProcUIDEvent struct {
+	ProcConnHeader
+	Pid  int
+	Tgid int
+	Ruid uint32
+	Euid uint32
+}
+
+type ProcGIDEvent struct {
+	ProcConnHeader
+	Pid  int
+	Tgid int
+	Rgid uint32
+	Egid uint32
+}
+
+type ProcCommEvent struct {
+	ProcConnHeader
+	Pid  int
+	Tgid int
+	Comm string
+}
+
+func sendProcCnRequest(skfd int, op uint32) error {
+	var req ProcCnRequest
+	req.Header.Len = SizeOfProcCnRequest
+	req.Header.Type = unix.NLMSG_DONE
+	req.Header.Pid = uint32(os.Getpid())
+	req.Msg.ID.Idx = CN_IDX_PROC
+	req.Msg.ID.Val = CN_VAL_PROC
+	req.Msg.Len = 4
+	req.Op = op
+	buf := (*[SizeOfProcCnRequest]byte)(unsafe.Pointer(&req))[:]
+	return unix.Sendto(skfd, buf, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: CN_IDX_PROC})
+}
+
+// parseProcEvent decodes the proc_event following the cn_msg of a netlink message.
+// It returns nil for the event kinds not reported. Acknowledgements of subscriptions
+// are multicast like the events, their error is returned only when handshake is set.
+func parseProcEvent(data []byte, handshake bool) (ProcConnEvent, error) {
+	if len(data) < SizeOfCnMsg+SizeOfProcEventHeader {
+		return nil, fmt.Errorf("message too short:[%d]", len(data))
+	}
+	msg := *(*CnMsg)(unsafe.Pointer(&data[0]))
+	if msg.ID.Idx != CN_IDX_PROC || msg.ID.Val != CN_VAL_PROC {
+		return nil, nil
+	}
+	ev := data[SizeOfCnMsg:]
+	what := *(*uint32)(unsafe.Pointer(&ev[0]))
+	h := ProcConnHeader{
+		CPU:       *(*uint32)(unsafe.Pointer(&ev[4])),
+		Timestamp: *(*uint64)(unsafe.Pointer(&ev[8])),
+	}
+	body := ev[SizeOfProcEventHeader:]
+	u32 := func(i int) uint32 {
+		if len(body) < 4*(i+1) {
+			return 0
+		}
+		return *(*uint32)(unsafe.Pointer(&body[4*i]))
+	}
+	switch what {
+	case PROC_EVENT_NONE:
+		if handshake && u32(0) != 0 {
+			return nil, fmt.Errorf("%w: %v", ErrProcConnectorUnavailable, syscall.Errno(u32(0)))
+		}
+	case PROC_EVENT_FORK:
+		return &ProcForkEvent{ProcConnHeader: h, ParentPid: int(u32(0)), ParentTgid: int(u32(1)), ChildPid: int(u32(2)), ChildTgid: int(u32(3))}, nil
+	case PROC_EVENT_EXEC:
+		return &ProcExecEvent{ProcConnHeader: h, Pid: int(u32(0)), Tgid: int(u32(1))}, nil
+	case PROC_EVENT_EXIT:
+		return &ProcExitEvent{ProcConnHeader: h, Pid: int(u32(0)), Tgid: int(u32(1)), ExitCode: u32(2), ExitSignal: u32(3)}, nil
+	case PROC_EVENT_UID:
+		return &ProcUIDEvent{ProcConnHeader: h, Pid: int(u32(0)), Tgid: int(u32(1)), Ruid: u32(2), Euid: u32(3)}, nil
+	case PROC_EVENT_GID:
+		return &ProcGIDEvent{ProcConnHeader: h, Pid: int(u32(0)), Tgid: int(u32(1)), Rgid: u32(2), Egid: u32(3)}, nil
+	case PROC_EVENT_COMM:
+		e := &ProcCommEvent{ProcConnHeader: h, Pid: int(u32(0)), Tgid: int(u32(1))}
+		if len(body) > 8 {
+			comm := body[8:]
+			for i := range comm {
+				if comm[i] == 0 {
+					comm = comm[:i]
+					break
+				}
+			}
+			e.Comm = string(comm)
+		}
+		return e, nil
+	}
+	return nil, nil
+}
+
+// SubscribeProcEvents streams the process events of the whole system until ctx is done,
+// the channel being closed then. Errors wrapping ErrProcConnectorUnavailable tell
+// the caller to fall back to scanning, as WatchProcEvents does.
+func SubscribeProcEvents(ctx context.Context) (<-chan ProcConnEvent, error) {
+	// the kernel ignores subscriptions from other namespaces without acknowledging them
+	if !inInitUserNS() {
+		return nil, fmt.Errorf("%w: not in the initial user namespace", ErrProcConnectorUnavailable)
+	}
+	skfd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, unix.NETLINK_CONNECTOR)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrProcConnectorUnavailable, err)
+	}
+	if err = unix.Bind(skfd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: CN_IDX_PROC}); err != nil {
+		unix.Close(skfd)
+		return nil, fmt.Errorf("%w: %v", ErrProcConnectorUnavailable, err)
+	}
+	tv := unix.NsecToTimeval(ProcConnectorRecvTimeout.Nanoseconds())
+	if err = unix.SetsockoptTimeval(skfd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		unix.Close(skfd)
+		return nil, err
+	}
+	if err = sendProcCnRequest(skfd, PROC_CN_MCAST_LISTEN); err != nil {
+		unix.Close(skfd)
+		return nil, fmt.Errorf("%w: %v", ErrProcConnectorUnavailable, err)
+	}
+
+	// the kernel acknowledges the subscription with an event carrying its error, if any
+	buf := make([]byte, OSPageSize)
+	first, err := recvProcEvents(skfd, buf, true)
+	if err != nil && err != unix.EAGAIN {
+		unix.Close(skfd)
+		return nil, err
+	}
+
+	events := make(chan ProcConnEvent, 256)
+	go func() {
+		defer func() {
+			sendProcCnRequest(skfd, PROC_CN_MCAST_IGNORE)
+			unix.Close(skfd)
+			close(events)
+		}()
+		pending := first
+		for {
+			for _, e := range pending {
+				select {
+				case events <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+			// timeouts let cancellation be noticed, overruns lose events but not the subscription
+			if pending, err = recvProcEvents(skfd, buf, false); err != nil && err != unix.EAGAIN && err != unix.EINTR && err != unix.ENOBUFS {
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+func inInitUserNS() bool {
+	raw, err := ioutil.ReadFile(ProcRoot + "/self/uid_map")
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(raw))
+	return len(fields) == 3 && fields[0] == "0" && fields[1] == "0" && fields[2] == "4294967295"
+}
+
+func recvProcEvents(skfd int, buf []byte, handshake bool) ([]ProcConnEvent, error) {
+	n, _, err := unix.Recvfrom(skfd, buf, 0)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+	if err != nil {
+		return nil, err
+	}
+	events := make([]ProcConnEvent, 0, len(msgs))
+	for _, msg := range msgs {
+		e, err := parseProcEvent(msg.Data, handshake)
+		if err != nil {
+			return nil, err
+		}
+		if e != nil {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// WatchProcEvents subscribes to the proc connector, or when it is unavailable polls the processes
+// every interval with a ProcCache of its own, reporting appeared processes as forks and
+// vanished ones as exits with Polled set: processes living less than interval are missed,
+// exit codes are unknown. The returned error is that of the subscription, nil when subscribed.
+func WatchProcEvents(ctx context.Context, interval time.Duration) (<-chan ProcConnEvent, error) {
+	events, err := SubscribeProcEvents(ctx)
+	if err == nil {
+		return events, nil
+	}
+	polled := make(chan ProcConnEvent, 256)
+	go pollProcEvents(ctx, interval, polled)
+	return polled, err
+}
+
+func pollProcEvents(ctx context.Context, interval time.Duration, events chan<- ProcConnEvent) {
+	defer close(events)
+	cache := NewProcCache(NewProcScanner(1))
+	if _, _, err := cache.Scan(0); err != nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		_, pes, err := cache.Scan(0)
+		if err != nil {
+			continue
+		}
+		for _, pe := range pes {
+			var e ProcConnEvent
+			h := ProcConnHeader{Polled: true}
+			switch pe.Type {
+			case ProcAppeared:
+				e = &ProcForkEvent{ProcConnHeader: h, ParentPid: pe.Proc.Stat.Ppid, ParentTgid: pe.Proc.Stat.Ppid, ChildPid: pe.Proc.Stat.Pid, ChildTgid: pe.Proc.Stat.Pid}
+			case ProcExited:
+				e = &ProcExitEvent{ProcConnHeader: h, Pid: pe.Proc.Stat.Pid, Tgid: pe.Proc.Stat.Pid}
+			default:
+				continue
+			}
+			select {
+			case events <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
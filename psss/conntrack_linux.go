@@ -0,0 +1,448 @@
+// +build linux
+
+package psss
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// definition comes from Linux kernel /include/uapi/linux/netfilter/nfnetlink_conntrack.h
+// and nf_conntrack_common.h, values of ctnetlink attributes are in network byte order
+const (
+	IPCTNL_MSG_CT_GET = 1
+
+	CTA_TUPLE_ORIG  = 1
+	CTA_TUPLE_REPLY = 2
+	CTA_STATUS      = 3
+	CTA_PROTOINFO   = 4
+	CTA_TIMEOUT     = 7
+	CTA_MARK        = 8
+	CTA_ZONE        = 18
+
+	CTA_TUPLE_IP    = 1
+	CTA_TUPLE_PROTO = 2
+
+	CTA_IP_V4_SRC = 1
+	CTA_IP_V4_DST = 2
+	CTA_IP_V6_SRC = 3
+	CTA_IP_V6_DST = 4
+
+	CTA_PROTO_NUM      = 1
+	CTA_PROTO_SRC_PORT = 2
+	CTA_PROTO_DST_PORT = 3
+
+	CTA_PROTOINFO_TCP       = 1
+	CTA_PROTOINFO_TCP_STATE = 1
+
+	IPS_SEEN_REPLY = 0x2
+	IPS_ASSURED    = 0x4
+
+	SizeofNfgenmsg = 4
+
+	nlaTypeMask = ^uint16(unix.NLA_F_NESTED | unix.NLA_F_NET_BYTEORDER)
+)
+
+var (
+	// ConntrackTCPStates names the TCP states of conntrack, which are not those of the sockets.
+	ConntrackTCPStates = []string{"NONE", "SYN_SENT", "SYN_RECV", "ESTABLISHED", "FIN_WAIT", "CLOSE_WAIT", "LAST_ACK", "TIME_WAIT", "CLOSE", "SYN_SENT2"}
+)
+
+// ConntrackTuple is one direction of a tracked connection. Ports are 0 for protocols without them, such as icmp.
+type ConntrackTuple struct {
+	Src     net.IP
+	Dst     net.IP
+	SrcPort uint16
+	DstPort uint16
+}
+
+// ConntrackEntry is a connection tracked by netfilter, from ctnetlink or /proc/net/nf_conntrack.
+type ConntrackEntry struct {
+	Family   uint8
+	Proto    uint8
+	Original ConntrackTuple
+	Reply    ConntrackTuple // differs from the swapped Original when the connection is NATed
+	State    string         // one of ConntrackTCPStates for tcp, empty for connectionless protocols
+	Timeout  uint32         // seconds left before the entry expires
+	Status   uint32         // IPS_* bits
+	Mark     uint32
+	Zone     uint16
+}
+
+func (ce *ConntrackEntry) ProtoName() string {
+	switch ce.Proto {
+	case unix.IPPROTO_TCP:
+		return "tcp"
+	case unix.IPPROTO_UDP:
+		return "udp"
+	case unix.IPPROTO_ICMP:
+		return "icmp"
+	case unix.IPPROTO_ICMPV6:
+		return "icmpv6"
+	case unix.IPPROTO_SCTP:
+		return "sctp"
+	case unix.IPPROTO_DCCP:
+		return "dccp"
+	case unix.IPPROTO_UDPLITE:
+		return "udplite"
+	case unix.IPPROTO_GRE:
+		return "gre"
+	}
+	return strconv.Itoa(int(ce.Proto))
+}
+
+// Assured entries have seen traffic both ways and are the last to be evicted when the table is full.
+func (ce *ConntrackEntry) Assured() bool {
+	return ce.Status&IPS_ASSURED != 0
+}
+
+// Unreplied entries have only seen the original direction, such as scans or unanswered udp.
+func (ce *ConntrackEntry) Unreplied() bool {
+	return ce.Status&IPS_SEEN_REPLY == 0
+}
+
+// ConntrackEntries are the connections tracked in the network namespace of the caller.
+type ConntrackEntries []*ConntrackEntry
+
+func NewConntrackEntries() ConntrackEntries {
+	return make(ConntrackEntries, 0)
+}
+
+// Get dumps the IPv4 and IPv6 entries over ctnetlink, which needs CAP_NET_ADMIN,
+// or reads /proc/net/nf_conntrack when that fails.
+func (ces *ConntrackEntries) Get() error {
+	err := ces.getNetlink()
+	if err == nil {
+		return nil
+	}
+	*ces = (*ces)[:0]
+	if procErr := ces.ReadProc(); procErr != nil {
+		return fmt.Errorf("netlink error:[%v], proc error:[%v]", err, procErr)
+	}
+	return nil
+}
+
+func (ces *ConntrackEntries) getNetlink() error {
+	skfd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_NETFILTER)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(skfd)
+
+	for _, af := range []uint8{unix.AF_INET, unix.AF_INET6} {
+		req := make([]byte, unix.SizeofNlMsghdr+SizeofNfgenmsg)
+		*(*unix.NlMsghdr)(unsafe.Pointer(&req[0])) = unix.NlMsghdr{
+			Len:   uint32(len(req)),
+			Type:  unix.NFNL_SUBSYS_CTNETLINK<<8 | IPCTNL_MSG_CT_GET,
+			Flags: unix.NLM_F_REQUEST | unix.NLM_F_DUMP,
+			Seq:   uint32(af),
+		}
+		*(*unix.Nfgenmsg)(unsafe.Pointer(&req[unix.SizeofNlMsghdr])) = unix.Nfgenmsg{Nfgen_family: af, Version: unix.NFNETLINK_V0}
+		if err = unix.Sendto(skfd, req, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+			return err
+		}
+		if err = ces.recvNetlink(skfd, af); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ces *ConntrackEntries) recvNetlink(skfd int, af uint8) error {
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := unix.Recvfrom(skfd, buf, 0)
+		if err != nil {
+			return err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			switch m.Header.Type {
+			case unix.NLMSG_DONE:
+				return nil
+			case unix.NLMSG_ERROR:
+				if len(m.Data) >= 4 {
+					if errno := -*(*int32)(unsafe.Pointer(&m.Data[0])); errno != 0 {
+						return syscall.Errno(errno)
+					}
+				}
+				return nil
+			}
+			if len(m.Data) < SizeofNfgenmsg {
+				continue
+			}
+			ces.parseNetlink(af, m.Data[SizeofNfgenmsg:])
+		}
+	}
+}
+
+func (ces *ConntrackEntries) parseNetlink(af uint8, b []byte) {
+	ce := &ConntrackEntry{Family: af}
+	for _, attr := range parseNestedAttrs(b) {
+		switch attr.Attr.Type & nlaTypeMask {
+		case CTA_TUPLE_ORIG:
+			ce.Proto = parseConntrackTuple(attr.Value, &ce.Original)
+		case CTA_TUPLE_REPLY:
+			parseConntrackTuple(attr.Value, &ce.Reply)
+		case CTA_STATUS:
+			ce.Status = bigEndianUint32(attr.Value)
+		case CTA_TIMEOUT:
+			ce.Timeout = bigEndianUint32(attr.Value)
+		case CTA_MARK:
+			ce.Mark = bigEndianUint32(attr.Value)
+		case CTA_ZONE:
+			if len(attr.Value) >= 2 {
+				ce.Zone = binary.BigEndian.Uint16(attr.Value)
+			}
+		case CTA_PROTOINFO:
+			for _, info := range parseNestedAttrs(attr.Value) {
+				if info.Attr.Type&nlaTypeMask != CTA_PROTOINFO_TCP {
+					continue
+				}
+				for _, tcp := range parseNestedAttrs(info.Value) {
+					if tcp.Attr.Type&nlaTypeMask == CTA_PROTOINFO_TCP_STATE && len(tcp.Value) > 0 && int(tcp.Value[0]) < len(ConntrackTCPStates) {
+						ce.State = ConntrackTCPStates[tcp.Value[0]]
+					}
+				}
+			}
+		}
+	}
+	*ces = append(*ces, ce)
+}
+
+// parseConntrackTuple fills t from the nested CTA_TUPLE_* attributes and returns the protocol.
+func parseConntrackTuple(b []byte, t *ConntrackTuple) (proto uint8) {
+	for _, attr := range parseNestedAttrs(b) {
+		switch attr.Attr.Type & nlaTypeMask {
+		case CTA_TUPLE_IP:
+			for _, ip := range parseNestedAttrs(attr.Value) {
+				switch ip.Attr.Type & nlaTypeMask {
+				case CTA_IP_V4_SRC, CTA_IP_V6_SRC:
+					t.Src = append(net.IP(nil), ip.Value...)
+				case CTA_IP_V4_DST, CTA_IP_V6_DST:
+					t.Dst = append(net.IP(nil), ip.Value...)
+				}
+			}
+		case CTA_TUPLE_PROTO:
+			for _, p := range parseNestedAttrs(attr.Value) {
+				switch p.Attr.Type & nlaTypeMask {
+				case CTA_PROTO_NUM:
+					if len(p.Value) > 0 {
+						proto = p.Value[0]
+					}
+				case CTA_PROTO_SRC_PORT:
+					if len(p.Value) >= 2 {
+						t.SrcPort = binary.BigEndian.Uint16(p.Value)
+					}
+				case CTA_PROTO_DST_PORT:
+					if len(p.Value) >= 2 {
+						t.DstPort = binary.BigEndian.Uint16(p.Value)
+					}
+				}
+			}
+		}
+	}
+	return proto
+}
+
+func (ces *ConntrackEntries) ReadProc() error {
+	raw, err := ioutil.ReadFile(ProcRoot + "/net/nf_conntrack")
+	if err != nil {
+		return err
+	}
+	return ces.ParseProc(raw)
+}
+
+// ParseProc parses /proc/net/nf_conntrack, whose lines are
+// "ipv4 2 tcp 6 431999 ESTABLISHED src=... dst=... sport=... dport=... src=... dst=... sport=... dport=... [ASSURED] mark=0 zone=0 use=2",
+// the first src, dst, sport and dport being the original direction and the second ones the reply.
+func (ces *ConntrackEntries) ParseProc(raw []byte) error {
+	for _, line := range strings.Split(strings.TrimSpace(string(raw)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		family, err := strconv.ParseUint(fields[1], 10, 8)
+		if err != nil {
+			return fmt.Errorf("parse family of line:[%s] error:[%v]", line, err)
+		}
+		proto, err := strconv.ParseUint(fields[3], 10, 8)
+		if err != nil {
+			return fmt.Errorf("parse protocol of line:[%s] error:[%v]", line, err)
+		}
+		timeout, err := strconv.ParseUint(fields[4], 10, 32)
+		if err != nil {
+			return fmt.Errorf("parse timeout of line:[%s] error:[%v]", line, err)
+		}
+		ce := &ConntrackEntry{Family: uint8(family), Proto: uint8(proto), Timeout: uint32(timeout), Status: IPS_SEEN_REPLY}
+		t := &ce.Original
+		for _, field := range fields[5:] {
+			switch field {
+			case "[UNREPLIED]":
+				ce.Status &^= IPS_SEEN_REPLY
+				continue
+			case "[ASSURED]":
+				ce.Status |= IPS_ASSURED
+				continue
+			}
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				// only tcp, sctp and dccp have a state
+				ce.State = field
+				continue
+			}
+			switch kv[0] {
+			case "src":
+				if t.Src != nil {
+					t = &ce.Reply
+				}
+				t.Src = net.ParseIP(kv[1])
+			case "dst":
+				t.Dst = net.ParseIP(kv[1])
+			case "sport", "dport":
+				port, err := strconv.ParseUint(kv[1], 10, 16)
+				if err != nil {
+					return fmt.Errorf("parse %s of line:[%s] error:[%v]", kv[0], line, err)
+				}
+				if kv[0] == "sport" {
+					t.SrcPort = uint16(port)
+				} else {
+					t.DstPort = uint16(port)
+				}
+			case "mark":
+				if mark, err := strconv.ParseUint(kv[1], 10, 32); err == nil {
+					ce.Mark = uint32(mark)
+				}
+			case "zone":
+				if zone, err := strconv.ParseUint(kv[1], 10, 16); err == nil {
+					ce.Zone = uint16(zone)
+				}
+			}
+		}
+		if ce.Family == unix.AF_INET {
+			ce.Original.Src, ce.Original.Dst = ce.Original.Src.To4(), ce.Original.Dst.To4()
+			ce.Reply.Src, ce.Reply.Dst = ce.Reply.Src.To4(), ce.Reply.Dst.To4()
+		}
+		*ces = append(*ces, ce)
+	}
+	return nil
+}
+
+// ConntrackSummary aggregates the entries of the table.
+type ConntrackSummary struct {
+	Total     int
+	Assured   int
+	Unreplied int
+	Protocols map[string]int // by ProtoName
+	States    map[string]int // by State, entries without one are left out
+	Sources   map[string]int // by original source address
+}
+
+func (ces ConntrackEntries) Summary() *ConntrackSummary {
+	cs := &ConntrackSummary{
+		Total:     len(ces),
+		Protocols: make(map[string]int),
+		States:    make(map[string]int),
+		Sources:   make(map[string]int),
+	}
+	for _, ce := range ces {
+		if ce.Assured() {
+			cs.Assured++
+		}
+		if ce.Unreplied() {
+			cs.Unreplied++
+		}
+		cs.Protocols[ce.ProtoName()]++
+		if len(ce.State) > 0 {
+			cs.States[ce.State]++
+		}
+		cs.Sources[ce.Original.Src.String()]++
+	}
+	return cs
+}
+
+// ConntrackCount is a key of a ConntrackSummary aggregate and its number of entries.
+type ConntrackCount struct {
+	Key   string
+	Count int
+}
+
+// TopSources lists the n sources with the most entries, all of them when n is 0.
+func (cs *ConntrackSummary) TopSources(n int) []ConntrackCount {
+	return topConntrackCounts(cs.Sources, n)
+}
+
+func topConntrackCounts(counts map[string]int, n int) []ConntrackCount {
+	list := make([]ConntrackCount, 0, len(counts))
+	for k, c := range counts {
+		list = append(list, ConntrackCount{k, c})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Key < list[j].Key
+	})
+	if n > 0 && len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+// ConntrackUsage is the size of the conntrack table against its limit. New connections are dropped,
+// logging "nf_conntrack: table full, dropping packet", once Count reaches Max.
+type ConntrackUsage struct {
+	Count   uint64
+	Max     uint64
+	Buckets uint64
+}
+
+// Get reads /proc/sys/net/netfilter, which is missing while the nf_conntrack module is not loaded.
+func (cu *ConntrackUsage) Get() (err error) {
+	if cu.Count, err = readSysctlUint(ProcRoot + "/sys/net/netfilter/nf_conntrack_count"); err != nil {
+		return err
+	}
+	if cu.Max, err = readSysctlUint(ProcRoot + "/sys/net/netfilter/nf_conntrack_max"); err != nil {
+		return err
+	}
+	if cu.Buckets, err = readSysctlUint(ProcRoot + "/sys/net/netfilter/nf_conntrack_buckets"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Percent is Count out of Max, 0 when there is no limit.
+func (cu *ConntrackUsage) Percent() float64 {
+	if cu.Max == 0 {
+		return 0
+	}
+	return 100 * float64(cu.Count) / float64(cu.Max)
+}
+
+func readSysctlUint(path string) (uint64, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(raw)), 10, 64)
+}
+
+func bigEndianUint32(b []byte) uint32 {
+	if len(b) < 4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
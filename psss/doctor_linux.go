@@ -0,0 +1,296 @@
+// +build linux
+
+package psss
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Kinds of Finding.
+const (
+	FindingRetrans       = "retrans"
+	FindingLost          = "lost"
+	FindingZeroWindow    = "zero-window"
+	FindingKeepalive     = "keepalive"
+	FindingNotsent       = "notsent"
+	FindingSendQueue     = "send-q"
+	FindingRwndLimited   = "rwnd-limited"
+	FindingSndbufLimited = "sndbuf-limited"
+	FindingRTTOutlier    = "rtt-outlier"
+)
+
+// maxFindingScore caps the score of a single finding, so that one huge value does not hide the other findings.
+const maxFindingScore = 10.0
+
+// DoctorThresholds are the values from which a TCPInfo field makes a finding.
+// A finding scores the ratio of the value to its threshold, capped by maxFindingScore.
+type DoctorThresholds struct {
+	RetransPercent float64 // Total_retrans per hundred Segs_out
+	MinSegsOut     uint32  // segments to send before the retransmission rate means anything
+	Lost           uint32  // segments currently deemed lost
+	NotsentBytes   uint32  // written by the application but not sent yet
+	SendQueueBytes uint32  // sent but not acknowledged yet, Notsent_bytes left out
+	LimitedPercent float64 // share of Busy_time limited by the receive window or the send buffer
+	MinBusyTime    uint64  // usec of Busy_time before the limited shares mean anything
+	RTTFactor      float64 // times the median RTT of the remote subnet
+	MinRTTExcess   uint32  // usec above the median, so that fast subnets do not report sub-millisecond jitter
+	MinSubnetConns int     // connections to a remote subnet before its median is trusted
+	SubnetBits4    int     // prefix length grouping IPv4 peers
+	SubnetBits6    int     // prefix length grouping IPv6 peers
+}
+
+var DefaultDoctorThresholds = DoctorThresholds{
+	RetransPercent: 2,
+	MinSegsOut:     100,
+	Lost:           1,
+	NotsentBytes:   1 << 20,
+	SendQueueBytes: 1 << 20,
+	LimitedPercent: 20,
+	MinBusyTime:    100000,
+	RTTFactor:      3,
+	MinRTTExcess:   10000,
+	MinSubnetConns: 3,
+	SubnetBits4:    24,
+	SubnetBits6:    64,
+}
+
+// Finding is one symptom of a connection, explained for readers who do not know TCP internals.
+type Finding struct {
+	Kind    string
+	Score   float64
+	Explain string
+}
+
+// Diagnosis gathers the findings of a TCP connection.
+type Diagnosis struct {
+	Socket   SocketInfo
+	Findings []*Finding
+	Score    float64     // the sum of the scores of the findings
+	Procs    []*ProcInfo // see Diagnoses.SetUpProcs
+}
+
+func (d *Diagnosis) add(kind string, score float64, format string, a ...interface{}) {
+	if score > maxFindingScore {
+		score = maxFindingScore
+	}
+	d.Findings = append(d.Findings, &Finding{Kind: kind, Score: score, Explain: fmt.Sprintf(format, a...)})
+	d.Score += score
+}
+
+// ProcNames names the processes of the connection as name(pid), comma separated.
+func (d *Diagnosis) ProcNames() string {
+	names := make([]string, 0, len(d.Procs))
+	for _, p := range d.Procs {
+		names = append(names, fmt.Sprintf("%s(%d)", p.Stat.Name, p.Stat.Pid))
+	}
+	return strings.Join(names, ",")
+}
+
+// Diagnoses are sorted by decreasing score.
+type Diagnoses []*Diagnosis
+
+// Doctor reads the TCP connections of both address families along with their TCPInfo,
+// and ranks those with at least one finding.
+func Doctor(th DoctorThresholds) (Diagnoses, error) {
+	ssFilter, flagInfo := SsFilter, FlagInfo
+	SsFilter = 1<<SsESTAB | 1<<SsSYNSENT | 1<<SsFINWAIT1 | 1<<SsFINWAIT2 | 1<<SsCLOSEWAIT | 1<<SsLASTACK | 1<<SsCLOSING
+	FlagInfo = true
+	defer func() {
+		SsFilter, FlagInfo = ssFilter, flagInfo
+	}()
+
+	sis := make([]SocketInfo, 0)
+	for _, af := range []int{unix.AF_INET, unix.AF_INET6} {
+		list, err := GenericInetList(ProtocalTCP, af)
+		if err != nil {
+			return nil, err
+		}
+		sis = append(sis, list...)
+	}
+
+	ds := make([]*Diagnosis, len(sis))
+	for i := range sis {
+		ds[i] = &Diagnosis{Socket: sis[i]}
+		ds[i].examine(&th)
+	}
+	rttOutliers(ds, &th)
+
+	diagnoses := make(Diagnoses, 0)
+	for _, d := range ds {
+		if len(d.Findings) > 0 {
+			diagnoses = append(diagnoses, d)
+		}
+	}
+	sort.SliceStable(diagnoses, func(i, j int) bool {
+		return diagnoses[i].Score > diagnoses[j].Score
+	})
+	return diagnoses, nil
+}
+
+// examine looks at the fields of a single connection, the RTT apart.
+func (d *Diagnosis) examine(th *DoctorThresholds) {
+	si := &d.Socket
+	info := si.TCPInfo
+
+	// icsk_probes_out counts the probes of the timer running: zero window probes under the persist timer,
+	// keepalive probes under the keepalive timer
+	probes := si.Probes
+	if info != nil && int(info.Probes) > probes {
+		probes = int(info.Probes)
+	}
+	switch {
+	case si.Timer == 4:
+		unanswered := "this side keeps probing it"
+		if probes > 0 {
+			unanswered = fmt.Sprintf("%d probes asking it again got no room", probes)
+		}
+		d.add(FindingZeroWindow, float64(1+probes),
+			"the peer announced it cannot take more data (zero window) and %s: "+
+				"the application on the other side is not reading fast enough, or is stuck, so this side cannot send", unanswered)
+	case si.Timer == 2 && probes > 0:
+		d.add(FindingKeepalive, float64(probes),
+			"the peer did not answer %d keepalive probes: it may be gone, such as a crashed host or a NAT or firewall "+
+				"that forgot the connection, while the connection still looks open here", probes)
+	}
+	// the send queue of inet_diag runs from the first unacknowledged byte to the last written one
+	unacked := si.TxQueue
+	if info != nil && info.Notsent_bytes <= unacked {
+		unacked -= info.Notsent_bytes
+	}
+	if unacked >= th.SendQueueBytes && th.SendQueueBytes > 0 {
+		d.add(FindingSendQueue, float64(unacked)/float64(th.SendQueueBytes),
+			"%s sent are waiting to be acknowledged by the peer: the network or the peer is slow to confirm what it got", byteSize(uint64(unacked)))
+	}
+	if info == nil {
+		return
+	}
+
+	if info.Segs_out >= th.MinSegsOut && info.Segs_out > 0 && th.RetransPercent > 0 {
+		percent := 100 * float64(info.Total_retrans) / float64(info.Segs_out)
+		if percent >= th.RetransPercent {
+			d.add(FindingRetrans, percent/th.RetransPercent,
+				"%.1f%% of the packets sent had to be sent again (%d of %d): packets toward the peer get lost or delayed, "+
+					"and each loss makes TCP slow down", percent, info.Total_retrans, info.Segs_out)
+		}
+	}
+	if info.Lost >= th.Lost && th.Lost > 0 {
+		d.add(FindingLost, float64(info.Lost)/float64(th.Lost),
+			"%d packets are missing right now and wait to be sent again: the path to the peer is dropping packets at the moment", info.Lost)
+	}
+	if info.Notsent_bytes >= th.NotsentBytes && th.NotsentBytes > 0 {
+		d.add(FindingNotsent, float64(info.Notsent_bytes)/float64(th.NotsentBytes),
+			"%s written by the application have not even been sent yet: the connection cannot keep up with the writer, "+
+				"so its data reaches the peer late", byteSize(uint64(info.Notsent_bytes)))
+	}
+	if info.Busy_time >= th.MinBusyTime && info.Busy_time > 0 && th.LimitedPercent > 0 {
+		rwnd := 100 * float64(info.Rwnd_limited) / float64(info.Busy_time)
+		if rwnd >= th.LimitedPercent {
+			d.add(FindingRwndLimited, rwnd/th.LimitedPercent,
+				"%.0f%% of the time spent sending was waiting for the peer to make room: the peer reads slowly "+
+					"or its receive buffer is too small", rwnd)
+		}
+		sndbuf := 100 * float64(info.Sndbuf_limited) / float64(info.Busy_time)
+		if sndbuf >= th.LimitedPercent {
+			d.add(FindingSndbufLimited, sndbuf/th.LimitedPercent,
+				"%.0f%% of the time spent sending was waiting for room in the local send buffer: "+
+					"the buffer is too small for this connection, see net.ipv4.tcp_wmem or SO_SNDBUF", sndbuf)
+		}
+	}
+}
+
+// rttOutliers compares the RTT of each connection to the median RTT of the connections to the same remote subnet.
+func rttOutliers(ds []*Diagnosis, th *DoctorThresholds) {
+	if th.RTTFactor <= 0 {
+		return
+	}
+	groups := make(map[string][]*Diagnosis)
+	for _, d := range ds {
+		if d.Socket.TCPInfo == nil || d.Socket.TCPInfo.Rtt == 0 {
+			continue
+		}
+		if subnet := remoteSubnet(d.Socket.RemoteAddr.Host, th); len(subnet) > 0 {
+			groups[subnet] = append(groups[subnet], d)
+		}
+	}
+	for subnet, group := range groups {
+		if len(group) < th.MinSubnetConns {
+			continue
+		}
+		rtts := make([]uint32, 0, len(group))
+		for _, d := range group {
+			rtts = append(rtts, d.Socket.TCPInfo.Rtt)
+		}
+		sort.Slice(rtts, func(i, j int) bool {
+			return rtts[i] < rtts[j]
+		})
+		median := rtts[len(rtts)/2]
+		if len(rtts)%2 == 0 {
+			median = (rtts[len(rtts)/2-1] + rtts[len(rtts)/2]) / 2
+		}
+		if median == 0 {
+			continue
+		}
+		for _, d := range group {
+			rtt := d.Socket.TCPInfo.Rtt
+			if rtt < median+th.MinRTTExcess {
+				continue
+			}
+			factor := float64(rtt) / float64(median)
+			if factor >= th.RTTFactor {
+				d.add(FindingRTTOutlier, factor/th.RTTFactor,
+					"a round trip takes %.1fms, %.1f times the %.1fms of the %d connections to %s: "+
+						"this peer, or the way to it, is slower than its neighbours", float64(rtt)/1000, factor,
+					float64(median)/1000, len(group), subnet)
+			}
+		}
+	}
+}
+
+// remoteSubnet masks host with the prefix length of its family, IPv4 mapped addresses counting as IPv4.
+func remoteSubnet(host string, th *DoctorThresholds) string {
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsUnspecified() {
+		return ""
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(th.SubnetBits4, 32)), Mask: net.CIDRMask(th.SubnetBits4, 32)}).String()
+	}
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(th.SubnetBits6, 128)), Mask: net.CIDRMask(th.SubnetBits6, 128)}).String()
+}
+
+// byteSize writes n bytes in the largest binary unit below it.
+func byteSize(n uint64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fGiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fKiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", n)
+}
+
+// SetUpProcs finds the processes holding the connections, scanning the fds of every process.
+func (ds Diagnoses) SetUpProcs() error {
+	if len(ds) == 0 {
+		return nil
+	}
+	inodes := make([]uint32, 0, len(ds))
+	for _, d := range ds {
+		inodes = append(inodes, d.Socket.Inode)
+	}
+	procs, err := procsBySocketInode(inodes)
+	if err != nil {
+		return err
+	}
+	for _, d := range ds {
+		d.Procs = procs[d.Socket.Inode]
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+// +build linux
+
+package psss
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// PortExhaustionPercent is the usage above which a PortUsage is reported as close to exhaustion.
+var PortExhaustionPercent = 80.0
+
+// LocalPortRange is the range connect picks ephemeral ports from, less the reserved ports,
+// from net.ipv4.ip_local_port_range and net.ipv4.ip_local_reserved_ports, which IPv6 uses as well.
+type LocalPortRange struct {
+	Low      int
+	High     int
+	Reserved map[int]bool // only the reserved ports within Low and High
+}
+
+func (lpr *LocalPortRange) Get() error {
+	raw, err := ioutil.ReadFile(ProcRoot + "/sys/net/ipv4/ip_local_port_range")
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(raw))
+	if len(fields) != 2 {
+		return fmt.Errorf("invalid ip_local_port_range:[%s]", strings.TrimSpace(string(raw)))
+	}
+	if lpr.Low, err = strconv.Atoi(fields[0]); err != nil {
+		return err
+	}
+	if lpr.High, err = strconv.Atoi(fields[1]); err != nil {
+		return err
+	}
+	if raw, err = ioutil.ReadFile(ProcRoot + "/sys/net/ipv4/ip_local_reserved_ports"); err != nil {
+		return err
+	}
+	return lpr.ParseReserved(string(raw))
+}
+
+// ParseReserved parses the comma separated ports and port ranges of ip_local_reserved_ports, such as 8080,9000-9010.
+func (lpr *LocalPortRange) ParseReserved(raw string) error {
+	lpr.Reserved = make(map[int]bool)
+	raw = strings.TrimSpace(raw)
+	if len(raw) == 0 {
+		return nil
+	}
+	for _, item := range strings.Split(raw, ",") {
+		bounds := strings.SplitN(item, "-", 2)
+		low, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return fmt.Errorf("invalid reserved ports:[%s]", item)
+		}
+		high := low
+		if len(bounds) == 2 {
+			if high, err = strconv.Atoi(bounds[1]); err != nil {
+				return fmt.Errorf("invalid reserved ports:[%s]", item)
+			}
+		}
+		for port := low; port <= high; port++ {
+			if port >= lpr.Low && port <= lpr.High {
+				lpr.Reserved[port] = true
+			}
+		}
+	}
+	return nil
+}
+
+// Size is the number of ephemeral ports, the most connections a local address can open to one remote address and port.
+func (lpr *LocalPortRange) Size() int {
+	if lpr.High < lpr.Low {
+		return 0
+	}
+	return lpr.High - lpr.Low + 1 - len(lpr.Reserved)
+}
+
+func (lpr *LocalPortRange) IsEphemeral(port int) bool {
+	return port >= lpr.Low && port <= lpr.High && !lpr.Reserved[port]
+}
+
+// PortUsage is the number of ephemeral ports taken toward a remote address and port from a local address.
+// Ports are unique per such tuple only, so each tuple can use the whole range before connect fails with EADDRNOTAVAIL.
+type PortUsage struct {
+	LocalHost  string
+	RemoteAddr IP
+	InUse      int // TCP sockets of the tuple on an ephemeral port, TimeWait included
+	TimeWait   int // sockets holding their port for 2*MSL after an active close
+	Size       int // LocalPortRange.Size
+}
+
+func (pu *PortUsage) Percent() float64 {
+	if pu.Size == 0 {
+		return 100
+	}
+	return 100 * float64(pu.InUse) / float64(pu.Size)
+}
+
+// NearExhaustion tells the tuple uses more than PortExhaustionPercent of the ephemeral ports.
+func (pu *PortUsage) NearExhaustion() bool {
+	return pu.Percent() >= PortExhaustionPercent
+}
+
+// PortUsages are sorted by decreasing usage.
+type PortUsages []*PortUsage
+
+// GetPortUsages counts the TCP sockets of both address families on an ephemeral local port,
+// per local address and remote address and port. Listening sockets and those they accepted,
+// which share the port of the listener, are left out.
+func GetPortUsages() (*LocalPortRange, PortUsages, error) {
+	lpr := new(LocalPortRange)
+	if err := lpr.Get(); err != nil {
+		return nil, nil, err
+	}
+
+	ssFilter := SsFilter
+	SsFilter = (1 << SsMAX) - 1
+	defer func() {
+		SsFilter = ssFilter
+	}()
+
+	type tuple struct {
+		local  string
+		remote IP
+	}
+	sis := make([]SocketInfo, 0)
+	for _, af := range []int{unix.AF_INET, unix.AF_INET6} {
+		list, err := GenericInetList(ProtocalTCP, af)
+		if err != nil {
+			return nil, nil, err
+		}
+		sis = append(sis, list...)
+	}
+	listening := make(map[string]bool)
+	for _, si := range sis {
+		if si.Status == SsLISTEN {
+			listening[si.LocalAddr.Port] = true
+		}
+	}
+
+	usages := make(map[tuple]*PortUsage)
+	for _, si := range sis {
+		if si.Status == SsLISTEN || si.Status == SsUNCONN || listening[si.LocalAddr.Port] {
+			continue
+		}
+		port, err := strconv.Atoi(si.LocalAddr.Port)
+		if err != nil || !lpr.IsEphemeral(port) {
+			continue
+		}
+		t := tuple{si.LocalAddr.Host, si.RemoteAddr}
+		pu, ok := usages[t]
+		if !ok {
+			pu = &PortUsage{LocalHost: t.local, RemoteAddr: t.remote, Size: lpr.Size()}
+			usages[t] = pu
+		}
+		pu.InUse++
+		if si.Status == SsTIMEWAIT {
+			pu.TimeWait++
+		}
+	}
+
+	pus := make(PortUsages, 0, len(usages))
+	for _, pu := range usages {
+		pus = append(pus, pu)
+	}
+	sort.Slice(pus, func(i, j int) bool {
+		if pus[i].InUse != pus[j].InUse {
+			return pus[i].InUse > pus[j].InUse
+		}
+		if pus[i].LocalHost != pus[j].LocalHost {
+			return pus[i].LocalHost < pus[j].LocalHost
+		}
+		return pus[i].RemoteAddr.String() < pus[j].RemoteAddr.String()
+	})
+	return lpr, pus, nil
+}
+
+// NearExhaustion lists the tuples close to running out of ephemeral ports.
+func (pus PortUsages) NearExhaustion() PortUsages {
+	near := make(PortUsages, 0)
+	for _, pu := range pus {
+		if pu.NearExhaustion() {
+			near = append(near, pu)
+		}
+	}
+	return near
+}
+
+// Max is the most used tuple, nil when there is none.
+func (pus PortUsages) Max() *PortUsage {
+	if len(pus) == 0 {
+		return nil
+	}
+	return pus[0]
+}
@@ -26,6 +26,11 @@ const (
 	DT_WHT     = 14
 )
 
+// direntType converts the mode of a stat to the DT_* type of a dirent.
+func direntType(mode uint32) byte {
+	return byte((mode & unix.S_IFMT) >> 12)
+}
+
 type Dirent struct {
 	Inode  uint64
 	Offset uint64
@@ -144,61 +149,102 @@ type MountInfo struct {
 	FilesystemType string // the filesystem type in the form "type[.subtype]".
 	MountSource    string // filesystem-specific information or "none".
 	SuperOptions   string // per-superblock options (see mount(2)).
+	// Extra holds the optional fields by tag, such as shared, master or propagate_from, with their peer group ID.
+	// Tags without value, such as unbindable, are set to 0.
+	Extra    map[string]uint64
+	Warnings []*ParseWarning
 }
 
+// Parse fails when the mount cannot be identified, other malformed fields are left empty with a warning.
 func (mi *MountInfo) Parse(raw string) (err error) {
 	fields := strings.Fields(raw)
-	for i, v := range fields {
-		switch i {
-		case 0:
-			if mi.ID, err = strconv.ParseUint(v, 10, 64); err != nil {
-				return fmt.Errorf("parse id error:[%v]", err)
-			}
-		case 1:
-			if mi.ParentID, err = strconv.ParseUint(v, 10, 64); err != nil {
-				return fmt.Errorf("parse parent id error:[%v]", err)
-			}
-		case 2:
-			if mi.DiskMajorNum, err = strconv.ParseUint(strings.Split(v, ":")[0], 10, 64); err != nil {
-				return fmt.Errorf("parse parent id error:[%v]", err)
-			}
-			if mi.DiskMinorNum, err = strconv.ParseUint(strings.Split(v, ":")[1], 10, 64); err != nil {
-				return fmt.Errorf("parse parent id error:[%v]", err)
-			}
-		case 3:
-			mi.FileSystemRoot = v
-		case 4:
-			mi.MountPoint = v
-		case 5:
-			mi.MountOptions = v
-		case 6:
-			mi.OptionalFields = v
-		case 7:
-		case 8:
-			mi.FilesystemType = v
-		case 9:
-			mi.MountSource = v
-		case 10:
-			mi.SuperOptions = v
-		default:
-			fmt.Printf("invalid field:[%s] with index:[%d]\n", v, i)
+	// the optional fields end with a single hyphen
+	sep := -1
+	for i := 6; i < len(fields); i++ {
+		if fields[i] == "-" {
+			sep = i
+			break
 		}
 	}
+	if sep < 0 || len(fields) < sep+3 {
+		return fmt.Errorf("line:[%s] too short", raw)
+	}
+	if mi.ID, err = strconv.ParseUint(fields[0], 10, 64); err != nil {
+		return fmt.Errorf("parse id error:[%v]", err)
+	}
+	if mi.ParentID, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+		mi.warn(raw, fmt.Errorf("parse parent id error:[%v]", err))
+	}
+	dev := strings.Split(fields[2], ":")
+	if len(dev) != 2 {
+		mi.warn(raw, fmt.Errorf("invalid device:[%s]", fields[2]))
+	} else {
+		if mi.DiskMajorNum, err = strconv.ParseUint(dev[0], 10, 64); err != nil {
+			mi.warn(raw, fmt.Errorf("parse major error:[%v]", err))
+		}
+		if mi.DiskMinorNum, err = strconv.ParseUint(dev[1], 10, 64); err != nil {
+			mi.warn(raw, fmt.Errorf("parse minor error:[%v]", err))
+		}
+	}
+	mi.FileSystemRoot = fields[3]
+	mi.MountPoint = fields[4]
+	mi.MountOptions = fields[5]
+	mi.OptionalFields = strings.Join(fields[6:sep], " ")
+	for _, v := range fields[6:sep] {
+		if mi.Extra == nil {
+			mi.Extra = make(map[string]uint64)
+		}
+		tag := strings.SplitN(v, ":", 2)
+		if len(tag) == 1 {
+			mi.Extra[tag[0]] = 0
+			continue
+		}
+		id, err := strconv.ParseUint(tag[1], 10, 64)
+		if err != nil {
+			mi.warn(raw, fmt.Errorf("parse optional field:[%s] error:[%v]", v, err))
+			continue
+		}
+		mi.Extra[tag[0]] = id
+	}
+	mi.FilesystemType = fields[sep+1]
+	mi.MountSource = fields[sep+2]
+	if len(fields) > sep+3 {
+		mi.SuperOptions = fields[sep+3]
+	}
+	if len(fields) > sep+4 {
+		mi.warn(raw, fmt.Errorf("unknown fields:%v", fields[sep+4:]))
+	}
 	return nil
 }
 
+func (mi *MountInfo) warn(raw string, err error) {
+	mi.Warnings = append(mi.Warnings, &ParseWarning{ProcRoot + "/self/mountinfo", raw, err})
+}
+
 type MountInfos []*MountInfo
 
+// Warnings collects the warnings of all the mounts.
+func (mis MountInfos) Warnings() []*ParseWarning {
+	warnings := make([]*ParseWarning, 0)
+	for _, mi := range mis {
+		warnings = append(warnings, mi.Warnings...)
+	}
+	return warnings
+}
+
 func NewMountInfos() MountInfos {
 	return make([]*MountInfo, 0)
 }
 
+// Get reads /proc/self/mountinfo. A line naming no mount is a warning of the mount read before it,
+// or of the first one, see Warnings. It is an error only when no mount could be read at all.
 func (mis *MountInfos) Get() error {
 	fd, err := os.Open(ProcRoot + "/self/mountinfo")
 	if err != nil {
 		return err
 	}
 	defer fd.Close()
+	var rejected []*ParseWarning
 	scanner := bufio.NewScanner(fd)
 	for scanner.Scan() {
 		if err = scanner.Err(); err != nil {
@@ -206,10 +252,18 @@ func (mis *MountInfos) Get() error {
 		}
 		mi := new(MountInfo)
 		if err = mi.Parse(scanner.Text()); err != nil {
-			fmt.Printf("mount info parse error:[%v]\n", err)
+			rejected = append(rejected, &ParseWarning{ProcRoot + "/self/mountinfo", scanner.Text(), err})
+			if len(*mis) > 0 {
+				last := (*mis)[len(*mis)-1]
+				last.Warnings, rejected = append(last.Warnings, rejected...), nil
+			}
 			continue
 		}
+		mi.Warnings, rejected = append(rejected, mi.Warnings...), nil
 		*mis = append(*mis, mi)
 	}
+	if len(rejected) > 0 {
+		return rejected[0]
+	}
 	return nil
 }
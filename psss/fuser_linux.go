@@ -0,0 +1,284 @@
+// +build linux
+
+package psss
+
+import (
+	"fmt"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// How a FileUser uses the file, as reported by fuser.
+const (
+	FileAccessFd   = "fd"
+	FileAccessCwd  = "cwd"
+	FileAccessRoot = "root"
+	FileAccessExe  = "exe"
+	FileAccessMmap = "mmap"
+)
+
+// mountPointEscaper escapes a path the way the kernel writes it in mountinfo.
+var mountPointEscaper = strings.NewReplacer(`\`, `\134`, " ", `\040`, "\t", `\011`, "\n", `\012`)
+
+// MappedFile is a file mapped in the memory of a process, from /proc/[pid]/maps.
+type MappedFile struct {
+	Path  string // may end with " (deleted)"
+	Dev   uint64
+	Inode uint64
+}
+
+// ParseMappedFiles lists the files mapped in /proc/[pid]/maps, once each, leaving out anonymous mappings.
+func ParseMappedFiles(raw []byte) []*MappedFile {
+	type key struct{ dev, inode uint64 }
+	seen := make(map[key]bool)
+	mfs := make([]*MappedFile, 0)
+	for _, line := range strings.Split(string(raw), "\n") {
+		// address perms offset dev inode pathname
+		fields := strings.SplitN(line, " ", 6)
+		if len(fields) < 6 {
+			continue
+		}
+		inode, err := strconv.ParseUint(fields[4], 10, 64)
+		if err != nil || inode == 0 {
+			continue
+		}
+		dev := strings.Split(fields[3], ":")
+		if len(dev) != 2 {
+			continue
+		}
+		major, err := strconv.ParseUint(dev[0], 16, 32)
+		if err != nil {
+			continue
+		}
+		minor, err := strconv.ParseUint(dev[1], 16, 32)
+		if err != nil {
+			continue
+		}
+		k := key{unix.Mkdev(uint32(major), uint32(minor)), inode}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		mfs = append(mfs, &MappedFile{Path: strings.TrimLeft(fields[5], " "), Dev: k.dev, Inode: inode})
+	}
+	return mfs
+}
+
+func (p *ProcInfo) GetMappedFiles() error {
+	raw, err := p.readProcFile("maps")
+	if err != nil {
+		return err
+	}
+	p.MappedFiles = ParseMappedFiles(raw)
+	return nil
+}
+
+// FileUser is a process using a file, a listening socket or a mount.
+type FileUser struct {
+	Proc   *ProcInfo
+	User   string // name of Proc.UID, or the uid itself when unknown
+	Access string // one of the FileAccess* constants
+	Path   string
+	Fd     *OpenFile   // the fd, for FileAccessFd
+	Socket *SocketInfo // the listening socket, for WhoListens
+}
+
+// WhoListens finds the processes with a TCP socket in LISTEN state or an unconnected UDP socket bound to port.
+// proto is tcp, udp or empty for both, IPv4 and IPv6 sockets are both looked up.
+// Sockets of other network namespaces are not found.
+func WhoListens(port int, proto string) ([]*FileUser, error) {
+	var protocals []int
+	switch proto {
+	case "tcp":
+		protocals = []int{ProtocalTCP}
+	case "udp":
+		protocals = []int{ProtocalUDP}
+	case "":
+		protocals = []int{ProtocalTCP, ProtocalUDP}
+	default:
+		return nil, fmt.Errorf("invalid protocal:[%s]", proto)
+	}
+
+	ssFilter := SsFilter
+	SsFilter = 1<<SsLISTEN | 1<<SsUNCONN
+	defer func() {
+		SsFilter = ssFilter
+	}()
+	listening := make(map[uint32]SocketInfo)
+	p := strconv.Itoa(port)
+	for _, protocal := range protocals {
+		for _, af := range []int{unix.AF_INET, unix.AF_INET6} {
+			sis, err := GenericInetRead(protocal, af)
+			if err != nil {
+				continue
+			}
+			for inode, si := range sis {
+				if si.LocalAddr.Port == p && (protocal == ProtocalUDP || si.Status == SsLISTEN) {
+					listening[inode] = si
+				}
+			}
+		}
+	}
+	if len(listening) == 0 {
+		return nil, nil
+	}
+
+	procs, err := DefaultProcScanner.Scan(ProcReadOpenFiles)
+	if err != nil {
+		return nil, err
+	}
+	users := newFileUsers()
+	for _, proc := range procs {
+		for _, of := range proc.OpenFiles {
+			if of.Kind != FdKindSocket {
+				continue
+			}
+			if si, ok := listening[uint32(of.Inode)]; ok {
+				fu := users.add(proc, FileAccessFd, of.Target)
+				fu.Fd = of
+				fu.Socket = &si
+			}
+		}
+	}
+	return users.sorted(), nil
+}
+
+// WhoOpens finds the processes using the file at path: as an open fd, their working directory, root directory,
+// executable or a memory mapping. Files are compared by device and inode, so that hard links and bind mounts
+// of the same file are found as well.
+func WhoOpens(path string) ([]*FileUser, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return nil, fmt.Errorf("stat %s error:[%v]", path, err)
+	}
+	isDevice := st.Mode&unix.S_IFMT == unix.S_IFCHR || st.Mode&unix.S_IFMT == unix.S_IFBLK
+	return findFileUsers(
+		func(dev, inode uint64) bool {
+			return dev == st.Dev && inode == st.Ino
+		},
+		func(of *OpenFile) bool {
+			if of.Inode != st.Ino {
+				return false
+			}
+			// the Dev of a device fd is the device itself
+			if of.Kind == FdKindDevice {
+				return isDevice && of.Dev == st.Rdev
+			}
+			return of.Dev == st.Dev
+		})
+}
+
+// WhoUsesMount finds the processes using any file of the filesystem mounted at mountpoint,
+// which is looked up in the mount namespace of the caller.
+// Fds are matched by mount ID, the other uses by the device of the filesystem,
+// which also catches the other mounts of the same filesystem.
+func WhoUsesMount(mountpoint string) ([]*FileUser, error) {
+	mis := NewMountInfos()
+	if err := mis.Get(); err != nil {
+		return nil, err
+	}
+	escaped := mountPointEscaper.Replace(filepath.Clean(mountpoint))
+	var mount *MountInfo
+	for _, mi := range mis {
+		// mounts stacked on the same mount point are listed after the ones they hide
+		if mi.MountPoint == escaped {
+			mount = mi
+		}
+	}
+	if mount == nil {
+		return nil, fmt.Errorf("%s is not a mount point", mountpoint)
+	}
+	mountDev := unix.Mkdev(uint32(mount.DiskMajorNum), uint32(mount.DiskMinorNum))
+	return findFileUsers(
+		func(dev, inode uint64) bool {
+			return dev == mountDev
+		},
+		func(of *OpenFile) bool {
+			if of.MntID != 0 {
+				return uint64(of.MntID) == mount.ID
+			}
+			// kernels before 3.15 have no mnt_id in fdinfo
+			return of.Kind != FdKindDevice && of.Dev == mountDev
+		})
+}
+
+// findFileUsers scans the processes and checks their fds with matchFd,
+// their cwd, root, exe and mapped files with match.
+func findFileUsers(match func(dev, inode uint64) bool, matchFd func(of *OpenFile) bool) ([]*FileUser, error) {
+	procs, err := DefaultProcScanner.Scan(ProcReadOpenFiles | ProcReadLinks | ProcReadMaps)
+	if err != nil {
+		return nil, err
+	}
+	users := newFileUsers()
+	for _, proc := range procs {
+		links := []struct {
+			access string
+			path   string
+		}{
+			{FileAccessCwd, proc.Cwd},
+			{FileAccessRoot, proc.Root},
+			{FileAccessExe, proc.Exe},
+		}
+		for _, link := range links {
+			if len(link.path) == 0 {
+				continue
+			}
+			var st unix.Stat_t
+			// the link itself resolves across mount namespaces, unlike its target
+			if err := unix.Stat(ProcRoot+"/"+strconv.Itoa(proc.Stat.Pid)+"/"+link.access, &st); err != nil {
+				continue
+			}
+			if match(st.Dev, st.Ino) {
+				users.add(proc, link.access, link.path)
+			}
+		}
+		for _, mf := range proc.MappedFiles {
+			if match(mf.Dev, mf.Inode) {
+				users.add(proc, FileAccessMmap, mf.Path)
+			}
+		}
+		for _, of := range proc.OpenFiles {
+			if matchFd(of) {
+				users.add(proc, FileAccessFd, of.Target).Fd = of
+			}
+		}
+	}
+	return users.sorted(), nil
+}
+
+// fileUsers collects FileUsers, resolving each uid once.
+type fileUsers struct {
+	users []*FileUser
+	names map[uint32]string
+}
+
+func newFileUsers() *fileUsers {
+	return &fileUsers{names: make(map[uint32]string)}
+}
+
+func (fus *fileUsers) add(proc *ProcInfo, access, path string) *FileUser {
+	name, ok := fus.names[proc.UID]
+	if !ok {
+		name = strconv.FormatUint(uint64(proc.UID), 10)
+		if u, err := user.LookupId(name); err == nil {
+			name = u.Username
+		}
+		fus.names[proc.UID] = name
+	}
+	fu := &FileUser{Proc: proc, User: name, Access: access, Path: path}
+	fus.users = append(fus.users, fu)
+	return fu
+}
+
+// sorted returns the users by pid, keeping the order in which the uses of a process were added.
+func (fus *fileUsers) sorted() []*FileUser {
+	sort.SliceStable(fus.users, func(i, j int) bool {
+		return fus.users[i].Proc.Stat.Pid < fus.users[j].Proc.Stat.Pid
+	})
+	return fus.users
+}
@@ -5,6 +5,7 @@ package psss
 import (
 	"bufio"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"strconv"
 	"strings"
@@ -15,25 +16,33 @@ type DiskStat struct {
 	MajorNumber      uint64
 	MinorNumber      uint64
 	Name             string
-	ReadCompleted    uint64 // reads completed
-	ReadMerged       uint64 // reads merged, field 6 -- # of writes merged
-	SectorsRead      uint64 // sectors read
-	ReadingSpent     uint64 // milliseconds spent reading
-	WriteCompleted   uint64 // writes completed
-	WriteMerged      uint64 // writes merged
-	SectorsWritten   uint64 // sectors written
-	WritingSpent     uint64 // milliseconds spent writing
-	IOProgressing    uint64 // I/Os currently in progress
-	IOSpent          uint64 // milliseconds spent doing I/Os
-	WeightedIOSpent  uint64 // milliseconds spent doing I/Os (weighted)
-	DiscardCompleted uint64 // discards completed
-	DiscardMerged    uint64 // discards merged
-	SectorDiscarded  uint64 // sectors discarded
-	DiscardSpending  uint64 // milliseconds spent discarding
+	ReadCompleted    uint64            // reads completed
+	ReadMerged       uint64            // reads merged, field 6 -- # of writes merged
+	SectorsRead      uint64            // sectors read
+	ReadingSpent     uint64            // milliseconds spent reading
+	WriteCompleted   uint64            // writes completed
+	WriteMerged      uint64            // writes merged
+	SectorsWritten   uint64            // sectors written
+	WritingSpent     uint64            // milliseconds spent writing
+	IOProgressing    uint64            // I/Os currently in progress
+	IOSpent          uint64            // milliseconds spent doing I/Os
+	WeightedIOSpent  uint64            // milliseconds spent doing I/Os (weighted)
+	DiscardCompleted uint64            // discards completed
+	DiscardMerged    uint64            // discards merged
+	SectorDiscarded  uint64            // sectors discarded
+	DiscardSpending  uint64            // milliseconds spent discarding
+	FlushCompleted   uint64            // flush requests completed successfully
+	FlushSpending    uint64            // milliseconds spent flushing
+	Extra            map[string]uint64 // the fields following those known, keyed by their index from ReadCompleted as 0
+	Warnings         []*ParseWarning
 }
 
+// Parse fails when the device cannot be identified, malformed counters are left to 0 with a warning.
 func (ds *DiskStat) Parse(raw string) (err error) {
 	fields := strings.Fields(SlimSpaceRegExp.ReplaceAllString(raw, " "))
+	if len(fields) < 3 {
+		return fmt.Errorf("line:[%s] too short", raw)
+	}
 
 	if ds.MajorNumber, err = strconv.ParseUint(fields[0], 10, 64); err != nil {
 		return err
@@ -48,7 +57,8 @@ func (ds *DiskStat) Parse(raw string) (err error) {
 	var v uint64
 	for i, s := range fields {
 		if v, err = strconv.ParseUint(s, 10, 64); err != nil {
-			return err
+			ds.Warnings = append(ds.Warnings, &ParseWarning{ProcRoot + "/diskstats", raw, fmt.Errorf("parse field:[%s] error:[%v]", s, err)})
+			continue
 		}
 		switch i {
 		case 0:
@@ -81,8 +91,15 @@ func (ds *DiskStat) Parse(raw string) (err error) {
 			ds.SectorDiscarded = v
 		case 14:
 			ds.DiscardSpending = v
+		case 15:
+			ds.FlushCompleted = v
+		case 16:
+			ds.FlushSpending = v
 		default:
-			fmt.Printf("invalid field:[%s] with index:[%d]\n", s, i)
+			if ds.Extra == nil {
+				ds.Extra = make(map[string]uint64)
+			}
+			ds.Extra[strconv.Itoa(i)] = v
 		}
 	}
 
@@ -95,12 +112,15 @@ func NewDiskStats() DiskStats {
 	return make([]*DiskStat, 0)
 }
 
+// Get reads /proc/diskstats. A line naming no device is a warning of the device read before it,
+// or of the first one, see Warnings. It is an error only when no device could be read at all.
 func (dss *DiskStats) Get() (err error) {
 	fd, err := os.Open(ProcRoot + "/diskstats")
 	if err != nil {
 		return err
 	}
 	defer fd.Close()
+	var rejected []*ParseWarning
 	scanner := bufio.NewScanner(fd)
 	for scanner.Scan() {
 		if err = scanner.Err(); err != nil {
@@ -108,15 +128,32 @@ func (dss *DiskStats) Get() (err error) {
 		}
 		ds := new(DiskStat)
 		if err = ds.Parse(scanner.Text()); err != nil {
-			fmt.Printf("disk stat parse error:[%v]\n", err)
+			rejected = append(rejected, &ParseWarning{ProcRoot + "/diskstats", scanner.Text(), err})
+			if len(*dss) > 0 {
+				last := (*dss)[len(*dss)-1]
+				last.Warnings, rejected = append(last.Warnings, rejected...), nil
+			}
 			continue
 		}
+		ds.Warnings, rejected = append(rejected, ds.Warnings...), nil
 		*dss = append(*dss, ds)
 	}
+	if len(rejected) > 0 {
+		return rejected[0]
+	}
 
 	return nil
 }
 
+// Warnings collects the warnings of all the disks.
+func (dss DiskStats) Warnings() []*ParseWarning {
+	warnings := make([]*ParseWarning, 0)
+	for _, ds := range dss {
+		warnings = append(warnings, ds.Warnings...)
+	}
+	return warnings
+}
+
 type NetDev struct {
 	Interface          string
 	ReceiveBytes       uint64
@@ -135,22 +172,30 @@ type NetDev struct {
 	TransmitColls      uint64
 	TransmitCarrier    uint64
 	TransmitCompressed uint64
+	Extra              map[string]uint64 // the columns following those known, keyed by their index from ReceiveBytes as 0
+	Warnings           []*ParseWarning
 }
 
+// Parse fails when the line names no interface, malformed counters are left to 0 with a warning.
 func (nd *NetDev) Parse(raw string) (err error) {
-	fields := strings.Split(SlimSpaceRegExp.ReplaceAllString(raw, " "), ":")
+	fields := strings.SplitN(SlimSpaceRegExp.ReplaceAllString(raw, " "), ":", 2)
+	if len(fields) != 2 {
+		return fmt.Errorf("line:[%s] names no interface", raw)
+	}
 	nd.Interface = strings.TrimSpace(fields[0])
 
 	var fCtr int
 	var v uint64
 
 	fCtr = 0
-	for i, s := range strings.Fields(fields[1]) {
+	for _, s := range strings.Fields(fields[1]) {
 		if len(s) == 0 {
 			continue
 		}
 		if v, err = strconv.ParseUint(s, 10, 64); err != nil {
-			return fmt.Errorf("parse field:[%s] error:[%v]", s, err)
+			nd.Warnings = append(nd.Warnings, &ParseWarning{ProcRoot + "/self/net/dev", raw, fmt.Errorf("parse field:[%s] error:[%v]", s, err)})
+			fCtr++
+			continue
 		}
 		switch fCtr {
 		case 0:
@@ -186,7 +231,10 @@ func (nd *NetDev) Parse(raw string) (err error) {
 		case 15:
 			nd.TransmitCompressed = v
 		default:
-			fmt.Printf("invalid field:[%s] with index:[%d]\n", s, i)
+			if nd.Extra == nil {
+				nd.Extra = make(map[string]uint64)
+			}
+			nd.Extra[strconv.Itoa(fCtr)] = v
 		}
 		fCtr++
 	}
@@ -195,18 +243,33 @@ func (nd *NetDev) Parse(raw string) (err error) {
 
 type NetDevs map[string]*NetDev
 
+// Warnings collects the warnings of all the interfaces.
+func (nds NetDevs) Warnings() []*ParseWarning {
+	warnings := make([]*ParseWarning, 0)
+	for _, nd := range nds {
+		warnings = append(warnings, nd.Warnings...)
+	}
+	return warnings
+}
+
 func NewNetDevs() NetDevs {
 	return make(map[string]*NetDev)
 }
 
-func (nds *NetDevs) Get() error {
+// Get reads /proc/self/net/dev. A line naming no interface, headers apart, is a warning of the interface
+// read before it, or of the first one, see Warnings. It is an error only when no interface could be read at all.
+func (nds *NetDevs) Get() (err error) {
 	fd, err := os.Open(ProcRoot + "/self/net/dev")
 	if err != nil {
 		return err
 	}
 	defer fd.Close()
 
-	var lCtr int
+	var (
+		lCtr     int
+		last     *NetDev
+		rejected []*ParseWarning
+	)
 	scanner := bufio.NewScanner(fd)
 	for scanner.Scan() {
 		if err = scanner.Err(); err != nil {
@@ -219,13 +282,145 @@ func (nds *NetDevs) Get() error {
 
 		nd := new(NetDev)
 		if err = nd.Parse(scanner.Text()); err != nil {
-			fmt.Printf("net dev parse error:[%v]\n", err)
-			continue
-		}
-		if nd.Interface == "lo" {
+			rejected = append(rejected, &ParseWarning{ProcRoot + "/self/net/dev", scanner.Text(), err})
+			if last != nil {
+				last.Warnings, rejected = append(last.Warnings, rejected...), nil
+			}
 			continue
 		}
+		nd.Warnings, rejected = append(rejected, nd.Warnings...), nil
 		(*nds)[nd.Interface] = nd
+		last = nd
+	}
+	if len(rejected) > 0 {
+		return rejected[0]
 	}
 	return nil
 }
+
+// SoftnetStat is the receive backlog of a CPU, a line of /proc/net/softnet_stat.
+// definition comes from Linux kernel /net/core/net-procfs.c
+type SoftnetStat struct {
+	CPU            int
+	Processed      uint64 // packets processed from the backlog
+	Dropped        uint64 // packets dropped for a full backlog, see net.core.netdev_max_backlog
+	TimeSqueeze    uint64 // times net_rx_action ran out of budget or time with work left, see net.core.netdev_budget
+	CPUCollision   uint64 // times the transmit lock was found taken, always 0 on recent kernels
+	ReceivedRPS    uint64 // times the CPU was woken up by another to process packets steered by RPS
+	FlowLimitCount uint64 // packets dropped by the flow limit, since Linux 3.11
+	// gauges, since Linux 5.10 for BacklogLen and 6.0 for the queue lengths
+	BacklogLen      uint64
+	InputQueueLen   uint64
+	ProcessQueueLen uint64
+}
+
+// SoftnetStats is keyed by CPU.
+type SoftnetStats map[int]*SoftnetStat
+
+func NewSoftnetStats() SoftnetStats {
+	return make(SoftnetStats)
+}
+
+func (sss SoftnetStats) Get() error {
+	raw, err := ioutil.ReadFile(ProcRoot + "/net/softnet_stat")
+	if err != nil {
+		return err
+	}
+	return sss.Parse(raw)
+}
+
+// Parse decodes the hexadecimal columns, whose number depends on the kernel.
+// Before Linux 5.10, lines carry no CPU number and offline CPUs are skipped,
+// so CPU is the line number and may not match the actual CPU.
+func (sss SoftnetStats) Parse(raw []byte) error {
+	for i, line := range strings.Split(strings.TrimSpace(string(raw)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 10 {
+			return fmt.Errorf("line:[%s] too short", line)
+		}
+		values := make([]uint64, len(fields))
+		for j, field := range fields {
+			v, err := strconv.ParseUint(field, 16, 32)
+			if err != nil {
+				return fmt.Errorf("parse field:[%s] error:[%v]", field, err)
+			}
+			values[j] = v
+		}
+		ss := &SoftnetStat{
+			CPU:          i,
+			Processed:    values[0],
+			Dropped:      values[1],
+			TimeSqueeze:  values[2],
+			CPUCollision: values[8],
+			ReceivedRPS:  values[9],
+		}
+		if len(values) > 10 {
+			ss.FlowLimitCount = values[10]
+		}
+		if len(values) > 12 {
+			ss.BacklogLen = values[11]
+			ss.CPU = int(values[12])
+		}
+		if len(values) > 14 {
+			ss.InputQueueLen = values[13]
+			ss.ProcessQueueLen = values[14]
+		}
+		sss[ss.CPU] = ss
+	}
+	return nil
+}
+
+// Sub turns the counters into their increase since prev, CPUs absent from prev are dropped.
+// The kernel keeps them as 32 bit counters, which wrap within hours on a busy CPU.
+func (sss SoftnetStats) Sub(prev SoftnetStats) {
+	for cpu, ss := range sss {
+		prevss, ok := prev[cpu]
+		if !ok {
+			delete(sss, cpu)
+			continue
+		}
+		ss.Processed = softnetDelta(ss.Processed, prevss.Processed)
+		ss.Dropped = softnetDelta(ss.Dropped, prevss.Dropped)
+		ss.TimeSqueeze = softnetDelta(ss.TimeSqueeze, prevss.TimeSqueeze)
+		ss.CPUCollision = softnetDelta(ss.CPUCollision, prevss.CPUCollision)
+		ss.ReceivedRPS = softnetDelta(ss.ReceivedRPS, prevss.ReceivedRPS)
+		ss.FlowLimitCount = softnetDelta(ss.FlowLimitCount, prevss.FlowLimitCount)
+	}
+}
+
+func softnetDelta(cur, prev uint64) uint64 {
+	return uint64(uint32(cur) - uint32(prev))
+}
+
+// Add sums every value of other, gauges included, so that Div averages them.
+func (sss SoftnetStats) Add(other SoftnetStats) {
+	for cpu, ss := range sss {
+		otherss, ok := other[cpu]
+		if !ok {
+			continue
+		}
+		ss.Processed += otherss.Processed
+		ss.Dropped += otherss.Dropped
+		ss.TimeSqueeze += otherss.TimeSqueeze
+		ss.CPUCollision += otherss.CPUCollision
+		ss.ReceivedRPS += otherss.ReceivedRPS
+		ss.FlowLimitCount += otherss.FlowLimitCount
+		ss.BacklogLen += otherss.BacklogLen
+		ss.InputQueueLen += otherss.InputQueueLen
+		ss.ProcessQueueLen += otherss.ProcessQueueLen
+	}
+}
+
+func (sss SoftnetStats) Div(n uint64) {
+	for _, ss := range sss {
+		ss.Processed /= n
+		ss.Dropped /= n
+		ss.TimeSqueeze /= n
+		ss.CPUCollision /= n
+		ss.ReceivedRPS /= n
+		ss.FlowLimitCount /= n
+		ss.BacklogLen /= n
+		ss.InputQueueLen /= n
+		ss.ProcessQueueLen /= n
+	}
+}
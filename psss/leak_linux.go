@@ -0,0 +1,201 @@
+// +build linux
+
+package psss
+
+import (
+	"sort"
+	"time"
+)
+
+// LeakSample is the footprint of a process at one point in time.
+type LeakSample struct {
+	Time    time.Time
+	Rss     int64 // bytes
+	Fds     int
+	Threads int
+	Sockets int
+}
+
+// LeakThresholds are the growth rates, per second, beyond which a steadily growing metric is reported.
+// A zero threshold reports any steady growth.
+type LeakThresholds struct {
+	Rss     float64 // bytes
+	Fds     float64
+	Threads float64
+	Sockets float64
+}
+
+// FdUsage compares the open fds of a process with its RLIMIT_NOFILE soft limit.
+type FdUsage struct {
+	Open  int
+	Soft  uint64 // RlimitInfinity when unlimited
+	Ratio float64
+	// ExhaustedIn projects when the limit is reached at the current fd slope, 0 when the fds are not growing.
+	ExhaustedIn time.Duration
+}
+
+// LeakTrend is the history of a process and the least squares slopes of its metrics, per second.
+type LeakTrend struct {
+	Proc         *ProcInfo // as last sampled
+	Samples      []LeakSample
+	RssSlope     float64
+	FdsSlope     float64
+	ThreadsSlope float64
+	SocketsSlope float64
+	Growing      []string // names of the metrics growing steadily beyond their threshold
+	FdUsage      *FdUsage // nil when the limits were not read
+}
+
+// LeakDetector keeps the last Window samples of every process and flags those growing steadily:
+// never decreasing over at least MinSamples samples with a slope beyond Thresholds.
+type LeakDetector struct {
+	Window     int
+	MinSamples int
+	Thresholds LeakThresholds
+
+	scanner *ProcScanner
+	trends  map[ProcIdentity]*LeakTrend
+}
+
+func NewLeakDetector(window int) *LeakDetector {
+	d := new(LeakDetector)
+	d.Window = window
+	d.MinSamples = 3
+	d.scanner = NewProcScanner(1)
+	d.trends = make(map[ProcIdentity]*LeakTrend)
+	return d
+}
+
+// Sample scans the processes with their fds and limits and adds them.
+func (d *LeakDetector) Sample() error {
+	procs, err := d.scanner.Scan(ProcReadFds | ProcReadLimits)
+	if err != nil {
+		return err
+	}
+	d.Add(procs, time.Now())
+	return nil
+}
+
+// Add records a scan made at now, its processes should have been read with ProcReadFds and ProcReadLimits.
+// Processes absent from the scan are forgotten.
+func (d *LeakDetector) Add(procs []*ProcInfo, now time.Time) {
+	trends := make(map[ProcIdentity]*LeakTrend, len(procs))
+	for _, proc := range procs {
+		id := proc.Identity()
+		trend, ok := d.trends[id]
+		if !ok {
+			trend = new(LeakTrend)
+		}
+		trend.Proc = proc
+		s := LeakSample{
+			Time:    now,
+			Rss:     proc.Stat.Rss * int64(OSPageSize),
+			Fds:     proc.NumFds,
+			Threads: int(proc.Stat.NumThreads),
+		}
+		for _, fd := range proc.Fds {
+			if fd.Type == DT_SOCK {
+				s.Sockets++
+			}
+		}
+		trend.Samples = append(trend.Samples, s)
+		if d.Window > 0 && len(trend.Samples) > d.Window {
+			trend.Samples = trend.Samples[len(trend.Samples)-d.Window:]
+		}
+		trends[id] = trend
+	}
+	d.trends = trends
+}
+
+// Trends computes the trend of every process sampled at least MinSamples times.
+func (d *LeakDetector) Trends() []*LeakTrend {
+	trends := make([]*LeakTrend, 0, len(d.trends))
+	for _, trend := range d.trends {
+		if len(trend.Samples) < d.MinSamples || len(trend.Samples) < 2 {
+			continue
+		}
+		trend.fit(&d.Thresholds)
+		trends = append(trends, trend)
+	}
+	return trends
+}
+
+// Leaks returns the processes with at least one metric growing steadily, the fastest fd growth first.
+func (d *LeakDetector) Leaks() []*LeakTrend {
+	leaks := make([]*LeakTrend, 0)
+	for _, trend := range d.Trends() {
+		if len(trend.Growing) > 0 {
+			leaks = append(leaks, trend)
+		}
+	}
+	sort.Slice(leaks, func(i, j int) bool {
+		if leaks[i].FdsSlope != leaks[j].FdsSlope {
+			return leaks[i].FdsSlope > leaks[j].FdsSlope
+		}
+		return leaks[i].RssSlope > leaks[j].RssSlope
+	})
+	return leaks
+}
+
+func (t *LeakTrend) fit(th *LeakThresholds) {
+	metrics := []struct {
+		name      string
+		value     func(s *LeakSample) float64
+		slope     *float64
+		threshold float64
+	}{
+		{"rss", func(s *LeakSample) float64 { return float64(s.Rss) }, &t.RssSlope, th.Rss},
+		{"fds", func(s *LeakSample) float64 { return float64(s.Fds) }, &t.FdsSlope, th.Fds},
+		{"threads", func(s *LeakSample) float64 { return float64(s.Threads) }, &t.ThreadsSlope, th.Threads},
+		{"sockets", func(s *LeakSample) float64 { return float64(s.Sockets) }, &t.SocketsSlope, th.Sockets},
+	}
+	t.Growing = t.Growing[:0]
+	for _, m := range metrics {
+		*m.slope = t.slope(m.value)
+		if *m.slope > m.threshold && t.monotonic(m.value) {
+			t.Growing = append(t.Growing, m.name)
+		}
+	}
+
+	t.FdUsage = nil
+	if t.Proc.Limits == nil {
+		return
+	}
+	last := t.Samples[len(t.Samples)-1]
+	t.FdUsage = &FdUsage{Open: last.Fds, Soft: t.Proc.Limits.OpenFiles.Soft}
+	if t.FdUsage.Soft == RlimitInfinity || t.FdUsage.Soft == 0 {
+		return
+	}
+	t.FdUsage.Ratio = float64(last.Fds) / float64(t.FdUsage.Soft)
+	if t.FdsSlope > 0 && uint64(last.Fds) < t.FdUsage.Soft {
+		t.FdUsage.ExhaustedIn = time.Duration(float64(t.FdUsage.Soft-uint64(last.Fds)) / t.FdsSlope * float64(time.Second))
+	}
+}
+
+// slope is the least squares fit of value over the seconds elapsed since the first sample.
+func (t *LeakTrend) slope(value func(s *LeakSample) float64) float64 {
+	var sx, sy, sxx, sxy float64
+	n := float64(len(t.Samples))
+	for i := range t.Samples {
+		x := t.Samples[i].Time.Sub(t.Samples[0].Time).Seconds()
+		y := value(&t.Samples[i])
+		sx += x
+		sy += y
+		sxx += x * x
+		sxy += x * y
+	}
+	if d := n*sxx - sx*sx; d != 0 {
+		return (n*sxy - sx*sy) / d
+	}
+	return 0
+}
+
+// monotonic tells whether value never decreased and grew overall.
+func (t *LeakTrend) monotonic(value func(s *LeakSample) float64) bool {
+	for i := 1; i < len(t.Samples); i++ {
+		if value(&t.Samples[i]) < value(&t.Samples[i-1]) {
+			return false
+		}
+	}
+	return value(&t.Samples[len(t.Samples)-1]) > value(&t.Samples[0])
+}
@@ -0,0 +1,306 @@
+// +build linux
+
+package psss
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// SysClassNetRoot is where the sysfs attributes of the interfaces are read.
+var SysClassNetRoot = "/sys/class/net"
+
+var (
+	// OperStates names the IF_OPER_* values of IFLA_OPERSTATE, from RFC 2863.
+	OperStates = []string{"unknown", "notpresent", "down", "lowerlayerdown", "testing", "dormant", "up"}
+
+	linkNamesMutex sync.Mutex
+	linkNames      map[int]string
+	linkNamesTime  time.Time
+)
+
+// linkNamesMaxAge bounds how long LinkName keeps a renamed interface under its previous name.
+const linkNamesMaxAge = time.Second
+
+// LinkAddr is an address of an interface, from RTM_GETADDR.
+type LinkAddr struct {
+	IPNet *net.IPNet
+	Scope uint8  // RT_SCOPE_*, 0 for global, 253 for link and 254 for host
+	Label string // IPv4 only, such as eth0:1
+	Flags uint32 // IFA_F_*, such as IFA_F_TEMPORARY or IFA_F_DEPRECATED
+}
+
+// Link is a network interface, from RTM_GETLINK and /sys/class/net.
+type Link struct {
+	Index        int
+	Name         string
+	Type         uint16 // ARPHRD_*
+	Flags        uint32 // IFF_*, such as IFF_UP or IFF_LOOPBACK
+	MTU          uint32
+	OperState    string // one of OperStates
+	Carrier      bool
+	HardwareAddr net.HardwareAddr
+	Kind         string // the driver of virtual interfaces: bond, bridge, vlan, veth, macvlan...; empty for physical ones
+	MasterIndex  int    // the bond or bridge this interface is enslaved to, 0 if none
+	Master       string
+	SlaveKind    string // the kind of the master, bond or bridge
+	ParentIndex  int    // the lower device of a vlan or macvlan, or the peer of a veth
+	Parent       string // empty when the parent lives in another network namespace
+	VlanID       uint16
+	Speed        int    // Mb/s, -1 when the driver does not report it, such as for virtual interfaces or without carrier
+	Duplex       string // full, half or unknown
+	Addrs        []*LinkAddr
+}
+
+func (l *Link) IsUp() bool {
+	return l.Flags&unix.IFF_UP != 0
+}
+
+func (l *Link) IsLoopback() bool {
+	return l.Flags&unix.IFF_LOOPBACK != 0
+}
+
+// Links are the interfaces of the network namespace of the caller, keyed by index.
+type Links map[int]*Link
+
+func NewLinks() Links {
+	return make(Links)
+}
+
+// Get dumps the interfaces and their addresses over rtnetlink, then reads speed and duplex from sysfs.
+func (ls Links) Get() error {
+	msgs, err := netlinkRouteDump(unix.RTM_GETLINK, unix.AF_UNSPEC)
+	if err != nil {
+		return fmt.Errorf("dump links error:[%v]", err)
+	}
+	for _, m := range msgs {
+		if m.Header.Type != unix.RTM_NEWLINK {
+			continue
+		}
+		l, err := parseLink(&m)
+		if err != nil {
+			return err
+		}
+		ls[l.Index] = l
+	}
+	for _, l := range ls {
+		if master, ok := ls[l.MasterIndex]; ok {
+			l.Master = master.Name
+		}
+		if parent, ok := ls[l.ParentIndex]; ok && l.ParentIndex != l.Index {
+			l.Parent = parent.Name
+		}
+		l.readSysfs()
+	}
+
+	if msgs, err = netlinkRouteDump(unix.RTM_GETADDR, unix.AF_UNSPEC); err != nil {
+		return fmt.Errorf("dump addresses error:[%v]", err)
+	}
+	for _, m := range msgs {
+		if m.Header.Type != unix.RTM_NEWADDR {
+			continue
+		}
+		index, addr, err := parseLinkAddr(&m)
+		if err != nil {
+			return err
+		}
+		if l, ok := ls[index]; ok {
+			l.Addrs = append(l.Addrs, addr)
+		}
+	}
+	return nil
+}
+
+// ByName returns the interface named name, or nil.
+func (ls Links) ByName(name string) *Link {
+	for _, l := range ls {
+		if l.Name == name {
+			return l
+		}
+	}
+	return nil
+}
+
+// Sorted lists the interfaces by index.
+func (ls Links) Sorted() []*Link {
+	list := make([]*Link, 0, len(ls))
+	for _, l := range ls {
+		list = append(list, l)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Index < list[j].Index
+	})
+	return list
+}
+
+// LinkName names the interface of index, or returns the index itself when unknown.
+// The names are kept between calls, and read again for an unknown index or once linkNamesMaxAge old.
+func LinkName(index int) string {
+	linkNamesMutex.Lock()
+	defer linkNamesMutex.Unlock()
+	name, ok := linkNames[index]
+	if !ok || time.Since(linkNamesTime) > linkNamesMaxAge {
+		linkNames, linkNamesTime = currentLinkNames(), time.Now()
+		name, ok = linkNames[index]
+	}
+	if ok {
+		return name
+	}
+	return strconv.Itoa(index)
+}
+
+// netlinkRouteDump sends a dump request of typ over NETLINK_ROUTE and returns the replies.
+func netlinkRouteDump(typ, family int) ([]syscall.NetlinkMessage, error) {
+	raw, err := syscall.NetlinkRIB(typ, family)
+	if err != nil {
+		return nil, err
+	}
+	return syscall.ParseNetlinkMessage(raw)
+}
+
+func parseLink(m *syscall.NetlinkMessage) (*Link, error) {
+	if len(m.Data) < unix.SizeofIfInfomsg {
+		return nil, fmt.Errorf("link message too short:[%d]", len(m.Data))
+	}
+	info := (*unix.IfInfomsg)(unsafe.Pointer(&m.Data[0]))
+	l := &Link{Index: int(info.Index), Type: info.Type, Flags: info.Flags, Speed: -1}
+	attrs, err := syscall.ParseNetlinkRouteAttr(m)
+	if err != nil {
+		return nil, err
+	}
+	for _, attr := range attrs {
+		switch attr.Attr.Type {
+		case unix.IFLA_IFNAME:
+			l.Name = strings.TrimRight(string(attr.Value), "\x00")
+		case unix.IFLA_MTU:
+			l.MTU = nativeUint32(attr.Value)
+		case unix.IFLA_ADDRESS:
+			l.HardwareAddr = append(net.HardwareAddr(nil), attr.Value...)
+		case unix.IFLA_OPERSTATE:
+			if len(attr.Value) > 0 && int(attr.Value[0]) < len(OperStates) {
+				l.OperState = OperStates[attr.Value[0]]
+			}
+		case unix.IFLA_CARRIER:
+			l.Carrier = len(attr.Value) > 0 && attr.Value[0] != 0
+		case unix.IFLA_MASTER:
+			l.MasterIndex = int(nativeUint32(attr.Value))
+		case unix.IFLA_LINK:
+			l.ParentIndex = int(nativeUint32(attr.Value))
+		case unix.IFLA_LINKINFO:
+			l.parseLinkInfo(attr.Value)
+		}
+	}
+	return l, nil
+}
+
+// parseLinkInfo reads the nested IFLA_INFO_* attributes.
+func (l *Link) parseLinkInfo(b []byte) {
+	for _, attr := range parseNestedAttrs(b) {
+		switch attr.Attr.Type {
+		case unix.IFLA_INFO_KIND:
+			l.Kind = strings.TrimRight(string(attr.Value), "\x00")
+		case unix.IFLA_INFO_SLAVE_KIND:
+			l.SlaveKind = strings.TrimRight(string(attr.Value), "\x00")
+		case unix.IFLA_INFO_DATA:
+			if l.Kind != "vlan" {
+				continue
+			}
+			for _, data := range parseNestedAttrs(attr.Value) {
+				if data.Attr.Type == unix.IFLA_VLAN_ID && len(data.Value) >= 2 {
+					l.VlanID = *(*uint16)(unsafe.Pointer(&data.Value[0]))
+				}
+			}
+		}
+	}
+}
+
+// readSysfs reads speed and duplex, which the kernel only reports for interfaces with a carrier.
+func (l *Link) readSysfs() {
+	path := SysClassNetRoot + "/" + l.Name + "/"
+	if raw, err := ioutil.ReadFile(path + "speed"); err == nil {
+		if speed, err := strconv.Atoi(strings.TrimSpace(string(raw))); err == nil && speed > 0 {
+			l.Speed = speed
+		}
+	}
+	l.Duplex = "unknown"
+	if raw, err := ioutil.ReadFile(path + "duplex"); err == nil {
+		l.Duplex = strings.TrimSpace(string(raw))
+	}
+}
+
+func parseLinkAddr(m *syscall.NetlinkMessage) (int, *LinkAddr, error) {
+	if len(m.Data) < unix.SizeofIfAddrmsg {
+		return 0, nil, fmt.Errorf("address message too short:[%d]", len(m.Data))
+	}
+	msg := (*unix.IfAddrmsg)(unsafe.Pointer(&m.Data[0]))
+	addr := &LinkAddr{Scope: msg.Scope, Flags: uint32(msg.Flags)}
+	attrs, err := syscall.ParseNetlinkRouteAttr(m)
+	if err != nil {
+		return 0, nil, err
+	}
+	bits := 8 * net.IPv4len
+	if msg.Family == unix.AF_INET6 {
+		bits = 8 * net.IPv6len
+	}
+	var local, address net.IP
+	for _, attr := range attrs {
+		switch attr.Attr.Type {
+		case unix.IFA_LOCAL:
+			local = append(net.IP(nil), attr.Value...)
+		case unix.IFA_ADDRESS:
+			address = append(net.IP(nil), attr.Value...)
+		case unix.IFA_LABEL:
+			addr.Label = strings.TrimRight(string(attr.Value), "\x00")
+		case unix.IFA_FLAGS:
+			addr.Flags = nativeUint32(attr.Value)
+		}
+	}
+	// IFA_ADDRESS is the peer of point-to-point interfaces, IFA_LOCAL the address itself
+	ip := address
+	if local != nil {
+		ip = local
+	}
+	if ip == nil {
+		return 0, nil, fmt.Errorf("address message of link:[%d] without address", msg.Index)
+	}
+	addr.IPNet = &net.IPNet{IP: ip, Mask: net.CIDRMask(int(msg.Prefixlen), bits)}
+	return int(msg.Index), addr, nil
+}
+
+// parseNestedAttrs splits the attributes nested in the value of another.
+func parseNestedAttrs(b []byte) []syscall.NetlinkRouteAttr {
+	attrs := make([]syscall.NetlinkRouteAttr, 0)
+	for len(b) >= unix.SizeofRtAttr {
+		rta := (*unix.RtAttr)(unsafe.Pointer(&b[0]))
+		if int(rta.Len) < unix.SizeofRtAttr || int(rta.Len) > len(b) {
+			break
+		}
+		attrs = append(attrs, syscall.NetlinkRouteAttr{
+			Attr:  syscall.RtAttr{Len: rta.Len, Type: rta.Type},
+			Value: b[unix.SizeofRtAttr:rta.Len],
+		})
+		aligned := (int(rta.Len) + unix.NLA_ALIGNTO - 1) &^ (unix.NLA_ALIGNTO - 1)
+		if aligned > len(b) {
+			break
+		}
+		b = b[aligned:]
+	}
+	return attrs
+}
+
+func nativeUint32(b []byte) uint32 {
+	if len(b) < 4 {
+		return 0
+	}
+	return *(*uint32)(unsafe.Pointer(&b[0]))
+}
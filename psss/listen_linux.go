@@ -0,0 +1,236 @@
+// +build linux
+
+package psss
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// ListenSaturationPercent is the accept queue fill above which a Listener is reported as saturated.
+var ListenSaturationPercent = 80.0
+
+// Listener is a TCP socket in LISTEN state, whose queues inet_diag reports differently:
+// RxQueue is the number of connections waiting to be accepted and TxQueue the limit of that number.
+type Listener struct {
+	Addr    IP
+	Family  int
+	Queue   uint32 // established connections not accepted yet
+	Backlog uint32 // the backlog given to listen, capped by net.core.somaxconn; 0 when BacklogUnknown
+	// BacklogUnknown tells the listener was read from /proc/net/tcp as inet_diag failed, which reports no backlog.
+	// Such a listener is never reported full nor saturated.
+	BacklogUnknown bool
+	Inode          uint32
+	Procs          []*ProcInfo // the processes holding the socket, see Listeners.SetUpProcs
+}
+
+// Fill is the percent of the accept queue in use. It may exceed 100 by one connection,
+// the kernel dropping SYNs and ACKs only once the queue holds more than Backlog connections.
+func (l *Listener) Fill() float64 {
+	if l.BacklogUnknown {
+		return 0
+	}
+	if l.Backlog == 0 {
+		if l.Queue == 0 {
+			return 0
+		}
+		return 100
+	}
+	return 100 * float64(l.Queue) / float64(l.Backlog)
+}
+
+// Full tells the kernel drops the new connections of the listener, counted by ListenOverflows.
+func (l *Listener) Full() bool {
+	return !l.BacklogUnknown && l.Queue > l.Backlog
+}
+
+func (l *Listener) Saturated() bool {
+	return l.Full() || l.Fill() >= ListenSaturationPercent
+}
+
+// CappedBySomaxconn tells the backlog the application asked for was likely lowered to somaxconn.
+func (l *Listener) CappedBySomaxconn(somaxconn int) bool {
+	return !l.BacklogUnknown && somaxconn > 0 && int(l.Backlog) == somaxconn
+}
+
+// ProcNames names the processes of the listener as name(pid), comma separated.
+func (l *Listener) ProcNames() string {
+	names := make([]string, 0, len(l.Procs))
+	for _, p := range l.Procs {
+		names = append(names, fmt.Sprintf("%s(%d)", p.Stat.Name, p.Stat.Pid))
+	}
+	return strings.Join(names, ",")
+}
+
+// Listeners are sorted by decreasing fill.
+type Listeners []*Listener
+
+// GetListeners reads the TCP listeners of both address families, without their processes.
+func GetListeners() (Listeners, error) {
+	ssFilter := SsFilter
+	SsFilter = 1 << SsLISTEN
+	defer func() {
+		SsFilter = ssFilter
+	}()
+
+	ls := make(Listeners, 0)
+	for _, af := range []int{unix.AF_INET, unix.AF_INET6} {
+		sis, err := GenericInetList(ProtocalTCP, af)
+		if err != nil {
+			return nil, err
+		}
+		for _, si := range sis {
+			if si.Status != SsLISTEN {
+				continue
+			}
+			ls = append(ls, &Listener{
+				Addr:           si.LocalAddr,
+				Family:         af,
+				Queue:          si.RxQueue,
+				Backlog:        si.TxQueue,
+				BacklogUnknown: si.FromProc,
+				Inode:          si.Inode,
+			})
+		}
+	}
+	sort.SliceStable(ls, func(i, j int) bool {
+		if ls[i].Fill() != ls[j].Fill() {
+			return ls[i].Fill() > ls[j].Fill()
+		}
+		return ls[i].Queue > ls[j].Queue
+	})
+	return ls, nil
+}
+
+// SetUpProcs finds the processes holding the listeners, scanning the fds of every process.
+func (ls Listeners) SetUpProcs() error {
+	if len(ls) == 0 {
+		return nil
+	}
+	inodes := make([]uint32, 0, len(ls))
+	for _, l := range ls {
+		inodes = append(inodes, l.Inode)
+	}
+	procs, err := procsBySocketInode(inodes)
+	if err != nil {
+		return err
+	}
+	for _, l := range ls {
+		l.Procs = procs[l.Inode]
+	}
+	return nil
+}
+
+// procsBySocketInode scans the fds of every process for the sockets of inodes.
+// A process holding a socket in several fds is listed once.
+func procsBySocketInode(inodes []uint32) (map[uint32][]*ProcInfo, error) {
+	byInode := make(map[uint32][]*ProcInfo, len(inodes))
+	for _, inode := range inodes {
+		byInode[inode] = nil
+	}
+	procs, err := DefaultProcScanner.Scan(ProcReadOpenFiles)
+	if err != nil {
+		return nil, err
+	}
+	for _, proc := range procs {
+		for _, of := range proc.OpenFiles {
+			if of.Kind != FdKindSocket {
+				continue
+			}
+			held, ok := byInode[uint32(of.Inode)]
+			if ok && (len(held) == 0 || held[len(held)-1] != proc) {
+				byInode[uint32(of.Inode)] = append(held, proc)
+			}
+		}
+	}
+	return byInode, nil
+}
+
+// Saturated lists the saturated listeners.
+func (ls Listeners) Saturated() Listeners {
+	saturated := make(Listeners, 0)
+	for _, l := range ls {
+		if l.Saturated() {
+			saturated = append(saturated, l)
+		}
+	}
+	return saturated
+}
+
+// ListenCounters are the TcpExt counters of the connections a listener could not take.
+// ListenOverflows counts those dropped as the accept queue was full, ListenDrops all the dropped ones,
+// overflows included. Both are system wide, only the listener queues tell which service they belong to.
+type ListenCounters struct {
+	Overflows int64
+	Drops     int64
+}
+
+func (lc *ListenCounters) Get() error {
+	sc := NewSnmpCounters()
+	if err := sc.ReadNetstat(); err != nil {
+		return err
+	}
+	lc.Overflows = sc.Value("TcpExt", "ListenOverflows")
+	lc.Drops = sc.Value("TcpExt", "ListenDrops")
+	return nil
+}
+
+func (lc *ListenCounters) Sub(prev *ListenCounters) {
+	lc.Overflows -= prev.Overflows
+	lc.Drops -= prev.Drops
+}
+
+func (lc *ListenCounters) Add(other *ListenCounters) {
+	lc.Overflows += other.Overflows
+	lc.Drops += other.Drops
+}
+
+func (lc *ListenCounters) Div(n uint64) {
+	lc.Overflows /= int64(n)
+	lc.Drops /= int64(n)
+}
+
+// ReadSomaxconn reads net.core.somaxconn, the cap of the listen backlogs.
+func ReadSomaxconn() (int, error) {
+	v, err := readSysctlUint(ProcRoot + "/sys/net/core/somaxconn")
+	return int(v), err
+}
+
+// ListenReport relates the listener queues to the connections dropped by listeners over an interval.
+type ListenReport struct {
+	Somaxconn int
+	Listeners Listeners      // as of the end of the interval, with their processes
+	Counters  ListenCounters // the increase over the interval
+	Interval  time.Duration
+}
+
+// GetListenReport reads the counters, waits for interval, then reads the counters and the listeners.
+func GetListenReport(interval time.Duration) (*ListenReport, error) {
+	lr := &ListenReport{Interval: interval}
+	var err error
+	if lr.Somaxconn, err = ReadSomaxconn(); err != nil {
+		return nil, err
+	}
+	var prev ListenCounters
+	if err = prev.Get(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	time.Sleep(interval)
+	if err = lr.Counters.Get(); err != nil {
+		return nil, err
+	}
+	lr.Interval = time.Since(start)
+	lr.Counters.Sub(&prev)
+	if lr.Listeners, err = GetListeners(); err != nil {
+		return nil, err
+	}
+	if err = lr.Listeners.SetUpProcs(); err != nil {
+		return nil, err
+	}
+	return lr, nil
+}
@@ -0,0 +1,274 @@
+// +build linux
+
+package psss
+
+import (
+	"bufio"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Kinds of OpenFile, as classified by lsof.
+const (
+	FdKindFile      = "file"
+	FdKindDir       = "dir"
+	FdKindPipe      = "pipe"
+	FdKindSocket    = "socket"
+	FdKindAnonInode = "anon_inode"
+	FdKindMemfd     = "memfd"
+	FdKindDevice    = "device"
+	FdKindUnknown   = "unknown"
+)
+
+var (
+	// openFlags name the bits of OpenFile.Flags, O_RDONLY being the absence of O_WRONLY and O_RDWR.
+	openFlags = []struct {
+		flag uint32
+		name string
+	}{
+		{unix.O_WRONLY, "O_WRONLY"},
+		{unix.O_RDWR, "O_RDWR"},
+		{unix.O_APPEND, "O_APPEND"},
+		{unix.O_NONBLOCK, "O_NONBLOCK"},
+		{unix.O_DSYNC, "O_DSYNC"},
+		{unix.O_DIRECT, "O_DIRECT"},
+		{unix.O_LARGEFILE, "O_LARGEFILE"},
+		{unix.O_DIRECTORY, "O_DIRECTORY"},
+		{unix.O_NOFOLLOW, "O_NOFOLLOW"},
+		{unix.O_NOATIME, "O_NOATIME"},
+		{unix.O_CLOEXEC, "O_CLOEXEC"},
+		{unix.O_PATH, "O_PATH"},
+	}
+
+	// socketTables are the /proc/net files listing sockets, with the index of their inode column.
+	socketTables = []struct {
+		protocol    string
+		path        string
+		inodeColumn int
+	}{
+		{"tcp", "/proc/net/tcp", 9},
+		{"tcp6", "/proc/net/tcp6", 9},
+		{"udp", "/proc/net/udp", 9},
+		{"udp6", "/proc/net/udp6", 9},
+		{"udplite", "/proc/net/udplite", 9},
+		{"udplite6", "/proc/net/udplite6", 9},
+		{"raw", "/proc/net/raw", 9},
+		{"raw6", "/proc/net/raw6", 9},
+		{"unix", "/proc/net/unix", 6},
+		{"netlink", "/proc/net/netlink", 9},
+		{"packet", "/proc/net/packet", 8},
+	}
+)
+
+// OpenFile is an open fd of a process, classified from its /proc/[pid]/fd link and stat.
+type OpenFile struct {
+	Fd     int
+	Target string // link target, such as /var/log/messages, socket:[1234] or anon_inode:[eventpoll]
+	Kind   string // one of the FdKind* constants
+	// Subtype refines Kind: the protocol of a socket (tcp, udp6, unix, netlink...) once AttachSocketProtocols is called,
+	// the anon inode type (eventfd, eventpoll, timerfd, inotify...), char or block for devices, fifo for named pipes.
+	Subtype string
+	Inode   uint64
+	Dev     uint64 // device of the file, or the device number itself for devices
+	Pos     uint64 // file offset
+	Flags   uint32 // open flags, see FlagsString
+	MntID   int
+}
+
+// Path is the file path of regular files, directories, devices and named pipes, or an empty string.
+func (of *OpenFile) Path() string {
+	if strings.HasPrefix(of.Target, "/") && of.Kind != FdKindMemfd {
+		return strings.TrimSuffix(of.Target, " (deleted)")
+	}
+	return ""
+}
+
+// Deleted tells whether the file was unlinked while open.
+func (of *OpenFile) Deleted() bool {
+	return strings.HasPrefix(of.Target, "/") && strings.HasSuffix(of.Target, " (deleted)")
+}
+
+func (of *OpenFile) FlagsString() string {
+	names := make([]string, 0, 4)
+	if of.Flags&(unix.O_WRONLY|unix.O_RDWR) == 0 {
+		names = append(names, "O_RDONLY")
+	}
+	for _, f := range openFlags {
+		// flags implied on the architecture, such as O_LARGEFILE on 64 bits, are 0
+		if f.flag != 0 && of.Flags&f.flag == f.flag {
+			names = append(names, f.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// classify sets Kind and Subtype from the link target and the mode of the file.
+func (of *OpenFile) classify(mode uint32) {
+	switch {
+	case strings.HasPrefix(of.Target, "socket:["):
+		of.Kind = FdKindSocket
+	case strings.HasPrefix(of.Target, "pipe:["):
+		of.Kind = FdKindPipe
+	case strings.HasPrefix(of.Target, "anon_inode:"):
+		of.Kind = FdKindAnonInode
+		of.Subtype = strings.Trim(strings.TrimPrefix(of.Target, "anon_inode:"), "[]")
+	case strings.HasPrefix(of.Target, "/memfd:"):
+		of.Kind = FdKindMemfd
+	default:
+		switch mode & unix.S_IFMT {
+		case unix.S_IFREG:
+			of.Kind = FdKindFile
+		case unix.S_IFDIR:
+			of.Kind = FdKindDir
+		case unix.S_IFCHR:
+			of.Kind = FdKindDevice
+			of.Subtype = "char"
+		case unix.S_IFBLK:
+			of.Kind = FdKindDevice
+			of.Subtype = "block"
+		case unix.S_IFIFO:
+			of.Kind = FdKindPipe
+			of.Subtype = "fifo"
+		case unix.S_IFSOCK:
+			of.Kind = FdKindSocket
+		default:
+			of.Kind = FdKindUnknown
+		}
+	}
+}
+
+// parseFdinfo reads the pos, flags and mnt_id lines of /proc/[pid]/fdinfo/[fd].
+func (of *OpenFile) parseFdinfo(raw []byte) {
+	for _, line := range strings.Split(string(raw), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		switch fields[0] {
+		case "pos:":
+			of.Pos, _ = strconv.ParseUint(fields[1], 10, 64)
+		case "flags:":
+			flags, _ := strconv.ParseUint(fields[1], 8, 32)
+			of.Flags = uint32(flags)
+		case "mnt_id:":
+			of.MntID, _ = strconv.Atoi(fields[1])
+		}
+	}
+}
+
+// readOpenFiles lists and classifies the fds of p, those closed during the listing are left out.
+func (w *procWorker) readOpenFiles(p *ProcInfo) error {
+	fd, err := w.openat(w.path(p.Stat.Pid, "fd"), unix.O_DIRECTORY)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	fds := make([]int, 0)
+	var n int
+	for {
+		if n, err = unix.Getdents(fd, w.fdDirents); err != nil {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+		forEachDirent(w.fdDirents[:n], func(name []byte) {
+			if v, ok := parseDecimal(name); ok {
+				fds = append(fds, int(v))
+			}
+		})
+	}
+
+	sort.Ints(fds)
+
+	pid := strconv.Itoa(p.Stat.Pid)
+	ofs := make([]*OpenFile, 0, len(fds))
+	for _, v := range fds {
+		of := &OpenFile{Fd: v}
+		name := strconv.Itoa(v)
+		if of.Target = w.readlink(p.Stat.Pid, "fd/"+name); len(of.Target) == 0 {
+			continue
+		}
+		var st unix.Stat_t
+		if err = unix.Fstatat(w.procfd, pid+"/fd/"+name, &st, 0); err == nil {
+			of.Inode = st.Ino
+			of.Dev = st.Dev
+			if st.Mode&unix.S_IFMT == unix.S_IFCHR || st.Mode&unix.S_IFMT == unix.S_IFBLK {
+				of.Dev = st.Rdev
+			}
+		}
+		of.classify(st.Mode)
+		if raw, err := w.readFile(p.Stat.Pid, "fdinfo/"+name); err == nil {
+			of.parseFdinfo(raw)
+		}
+		ofs = append(ofs, of)
+	}
+	p.OpenFiles = ofs
+	return nil
+}
+
+// GetOpenFiles is the standalone counterpart of the ProcReadOpenFiles option.
+func (p *ProcInfo) GetOpenFiles() error {
+	procfd, err := unix.Open(ProcRoot, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(procfd)
+	w := newProcWorker()
+	w.procfd = procfd
+	return w.readOpenFiles(p)
+}
+
+// SocketProtocols maps the inode of every socket of the network namespace to its protocol,
+// read from the /proc/net tables.
+func SocketProtocols() (map[uint64]string, error) {
+	protocols := make(map[uint64]string)
+	for _, table := range socketTables {
+		fd, err := os.Open(table.path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		scanner := bufio.NewScanner(fd)
+		// skip the header
+		scanner.Scan()
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) <= table.inodeColumn {
+				continue
+			}
+			if inode, err := strconv.ParseUint(fields[table.inodeColumn], 10, 64); err == nil && inode != 0 {
+				protocols[inode] = table.protocol
+			}
+		}
+		err = scanner.Err()
+		fd.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return protocols, nil
+}
+
+// AttachSocketProtocols sets the Subtype of the socket fds of procs, scanned with ProcReadOpenFiles.
+// Sockets of other network namespaces are not found and keep an empty Subtype.
+func AttachSocketProtocols(procs []*ProcInfo) error {
+	protocols, err := SocketProtocols()
+	if err != nil {
+		return err
+	}
+	for _, proc := range procs {
+		for _, of := range proc.OpenFiles {
+			if of.Kind == FdKindSocket {
+				of.Subtype = protocols[of.Inode]
+			}
+		}
+	}
+	return nil
+}
@@ -2,11 +2,55 @@ package psss
 
 type ProcInfo struct {
 	Cmdline []string
+	Exe     string // target of /proc/[pid]/exe, empty when not permitted
+	UID     uint32 // owner of /proc/[pid]
 	Stat    ProcStat
-	IsEnd   bool
+	// Optional, see the ProcRead* options
+	Fds         map[uint32]Fd // keyed by inode, fds sharing an inode are found once
+	NumFds      int           // number of open fds, read with Fds
+	Status      *ProcStatus
+	IO          *ProcIO
+	Limits      *ProcLimits
+	Cwd         string
+	Root        string
+	Environ     []string
+	Memory      *ProcMemory
+	Cgroup      *ProcCgroup
+	Threads     map[int]*ProcStat // keyed by thread id
+	Schedstat   *ProcSchedstat
+	OpenFiles   []*OpenFile   // sorted by fd
+	MappedFiles []*MappedFile // files mapped in memory, once each
+	IsEnd       bool
 }
 
 func NewProcInfo() *ProcInfo {
 	p := new(ProcInfo)
 	return p
 }
+
+// ProcIdentity tells processes apart across scans, since pids are reused.
+type ProcIdentity struct {
+	Pid       int
+	Starttime uint64
+}
+
+func (p *ProcInfo) Identity() ProcIdentity {
+	return ProcIdentity{Pid: p.Stat.Pid, Starttime: p.Stat.Starttime}
+}
+
+const (
+	ProcAppeared = iota
+	ProcExited
+)
+
+var ProcEventType = []string{
+	"appeared",
+	"exited",
+}
+
+// ProcEvent reports a process seen for the first time, or gone since the previous scan.
+// For exited processes Proc holds the last sample taken.
+type ProcEvent struct {
+	Type int
+	Proc *ProcInfo
+}
@@ -3,10 +3,10 @@
 package psss
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"strconv"
 	"strings"
 	"syscall"
 )
@@ -99,45 +99,137 @@ func (p *ProcInfo) GetStat() (err error) {
 	if _, err = fileContentBuffer.ReadFrom(fd); err != nil {
 		return err
 	}
-	n, err := fmt.Sscanf(string(fileContentBuffer.Bytes()[:fileContentBuffer.Len()-1]),
-		`%d %s %c %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d`,
-		&p.Stat.Pid, &p.Stat.Name, &p.Stat.State,
-		&p.Stat.Ppid, &p.Stat.Pgrp, &p.Stat.Session, &p.Stat.TtyNr, &p.Stat.Tpgid,
-		&p.Stat.Flags, &p.Stat.Minflt, &p.Stat.Cminflt, &p.Stat.Majflt, &p.Stat.Cmajflt,
-		&p.Stat.Utime, &p.Stat.Stime, &p.Stat.Cutime, &p.Stat.Cstime,
-		&p.Stat.Priority, &p.Stat.Nice,
-		&p.Stat.NumThreads, &p.Stat.Itrealvalue, &p.Stat.Starttime,
-		&p.Stat.Vsize, &p.Stat.Rss, &p.Stat.Rsslim,
-		&p.Stat.Startcode, &p.Stat.Endcode, &p.Stat.Startstack,
-		&p.Stat.Kstkesp, &p.Stat.Kstkeip,
-		&p.Stat.Signal, &p.Stat.Blocked, &p.Stat.Sigignore, &p.Stat.Sigcatch,
-		&p.Stat.Wchan,
-		&p.Stat.Nswap, &p.Stat.Cnswap,
-		// since linux 2.1.22
-		&p.Stat.ExitSignal,
-		// since linux 2.2.8
-		&p.Stat.Processor,
-		// since linux 2.5.19
-		&p.Stat.RtPriority, &p.Stat.Policy,
-		// since linux 2.6.18
-		&p.Stat.DelayacctBlkioTicks,
-		// since linux 2.6.24
-		&p.Stat.GuestTime, &p.Stat.CguestTime,
-		// since linux 3.3
-		&p.Stat.StartData, &p.Stat.EndData, &p.Stat.StartBrk,
-		// since linux 3.5
-		&p.Stat.ArgStart, &p.Stat.ArgEnd, &p.Stat.EnvStart, &p.Stat.EnvEnd, &p.Stat.ExitCode,
+	return ParseProcStat(fileContentBuffer.Bytes(), &p.Stat)
+}
+
+// procStatFields is the number of fields following the state in /proc/[pid]/stat since linux 3.5.
+const procStatFields = 49
+
+// ParseProcStat parses the content of /proc/[pid]/stat into st.
+// The name is located by the last ')' so that names containing spaces or parens are kept intact,
+// and the numeric fields are parsed in place without intermediate strings.
+func ParseProcStat(raw []byte, st *ProcStat) error {
+	lparen := bytes.IndexByte(raw, '(')
+	rparen := bytes.LastIndexByte(raw, ')')
+	if lparen < 1 || rparen < lparen || rparen+3 >= len(raw) {
+		return fmt.Errorf("invalid stat:[%s]", raw)
+	}
+	pid, ok := parseDecimal(bytes.TrimSpace(raw[:lparen]))
+	if !ok {
+		return fmt.Errorf("invalid pid:[%s]", raw[:lparen])
+	}
+	st.Pid = int(pid)
+	if st.Name != string(raw[lparen+1:rparen]) {
+		st.Name = string(raw[lparen+1 : rparen])
+	}
+	st.State = raw[rparen+2]
+
+	var (
+		f     [procStatFields]uint64
+		n     int
+		start int
 	)
-	if err != nil {
-		return err
+	raw = raw[rparen+3:]
+	for i := 0; i <= len(raw) && n < procStatFields; i++ {
+		if i < len(raw) && raw[i] != ' ' && raw[i] != '\n' {
+			continue
+		}
+		if i > start {
+			if f[n], ok = parseDecimal(raw[start:i]); !ok {
+				return fmt.Errorf("invalid field:[%s] with index:[%d]", raw[start:i], n+3)
+			}
+			n++
+		}
+		start = i + 1
 	}
-	if n < 52 {
+	if n < procStatFields {
 		return fmt.Errorf("not enough param read")
 	}
-	p.Stat.Name = strings.TrimSuffix(strings.TrimPrefix(p.Stat.Name, "("), ")")
+
+	st.Ppid = int(int64(f[0]))
+	st.Pgrp = int(int64(f[1]))
+	st.Session = int(int64(f[2]))
+	st.TtyNr = int(int64(f[3]))
+	st.Tpgid = int(int64(f[4]))
+	st.Flags = uint32(f[5])
+	st.Minflt = f[6]
+	st.Cminflt = f[7]
+	st.Majflt = f[8]
+	st.Cmajflt = f[9]
+	st.Utime = f[10]
+	st.Stime = f[11]
+	st.Cutime = f[12]
+	st.Cstime = f[13]
+	st.Priority = int64(f[14])
+	st.Nice = int64(f[15])
+	st.NumThreads = int64(f[16])
+	st.Itrealvalue = int64(f[17])
+	st.Starttime = f[18]
+	st.Vsize = f[19]
+	st.Rss = int64(f[20])
+	st.Rsslim = f[21]
+	st.Startcode = f[22]
+	st.Endcode = f[23]
+	st.Startstack = f[24]
+	st.Kstkesp = f[25]
+	st.Kstkeip = f[26]
+	st.Signal = f[27]
+	st.Blocked = f[28]
+	st.Sigignore = f[29]
+	st.Sigcatch = f[30]
+	st.Wchan = f[31]
+	st.Nswap = f[32]
+	st.Cnswap = f[33]
+	// since linux 2.1.22
+	st.ExitSignal = int(int64(f[34]))
+	// since linux 2.2.8
+	st.Processor = int(int64(f[35]))
+	// since linux 2.5.19
+	st.RtPriority = f[36]
+	st.Policy = uint32(f[37])
+	// since linux 2.6.18
+	st.DelayacctBlkioTicks = f[38]
+	// since linux 2.6.24
+	st.GuestTime = f[39]
+	st.CguestTime = int64(f[40])
+	// since linux 3.3
+	st.StartData = f[41]
+	st.EndData = f[42]
+	st.StartBrk = f[43]
+	// since linux 3.5
+	st.ArgStart = f[44]
+	st.ArgEnd = f[45]
+	st.EnvStart = f[46]
+	st.EnvEnd = f[47]
+	st.ExitCode = int(int64(f[48]))
 	return nil
 }
 
+// parseDecimal parses an optionally signed decimal number.
+// Negative numbers are returned in two's complement so that callers can convert to the signed type they need.
+func parseDecimal(b []byte) (v uint64, ok bool) {
+	if len(b) == 0 {
+		return 0, false
+	}
+	neg := b[0] == '-'
+	if neg {
+		b = b[1:]
+		if len(b) == 0 {
+			return 0, false
+		}
+	}
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		v = v*10 + uint64(c-'0')
+	}
+	if neg {
+		v = -v
+	}
+	return v, true
+}
+
 func (p *ProcInfo) GetFds() (err error) {
 	fdPath := ProcRoot + fmt.Sprintf("/%d/fd", p.Stat.Pid)
 	file, err := os.Open(fdPath)
@@ -150,6 +242,8 @@ func (p *ProcInfo) GetFds() (err error) {
 		fd Fd
 		ok bool
 	)
+	p.Fds = make(map[uint32]Fd)
+	p.NumFds = 0
 	for fdDirentReader.ExternalDirent = range fdDirentReader.DataChan {
 		if fdDirentReader.ExternalDirent.IsEnd {
 			return
@@ -164,75 +258,48 @@ func (p *ProcInfo) GetFds() (err error) {
 			GlobalProcFds[p.Stat.Name][p.Stat.Pid] = make(map[uint32]Fd)
 		}
 		fd.Name = fdDirentReader.ExternalDirent.Name
+		fd.Inode = fdStat.Ino
+		fd.Type = direntType(fdStat.Mode)
 		fd.Fresh = true
+		p.NumFds++
 
 		GlobalProcFds[p.Stat.Name][p.Stat.Pid][uint32(fdStat.Ino)] = fd
+		p.Fds[uint32(fdStat.Ino)] = fd
 	}
 	return nil
 }
 
-func ScanProcFS(fdFlag bool) {
+func ScanProcFS(options uint32) {
 	defer func() {
 		ProcInfoChan <- &ProcInfo{IsEnd: true}
 	}()
-	fd, err := os.Open(ProcRoot)
+	procs, _, err := DefaultProcCache.Scan(options)
 	if err != nil {
 		return
 	}
-	defer fd.Close()
-
-	go procDirentReader.Scan(fd)
-	for procDirentReader.ExternalDirent = range procDirentReader.DataChan {
-		if procDirentReader.ExternalDirent.IsEnd {
-			return
-		}
-		proc := NewProcInfo()
-		if proc.Stat.Pid, err = strconv.Atoi(procDirentReader.ExternalDirent.Name); err != nil {
-			continue
-		}
-		if err = proc.GetCmdline(); err != nil {
-			continue
-		}
-		if err = proc.GetStat(); err != nil {
-			continue
-		}
-		if fdFlag {
-			if err = proc.GetFds(); err != nil {
-				fmt.Printf("get fds error:[%v]\n", err)
-			}
-		}
+	for _, proc := range procs {
 		ProcInfoChan <- proc
 	}
 }
 
-func GetProcInfo(nameSet map[string]bool, fdFlag bool) map[string]map[int]*ProcInfo {
-	defer recover()
-
+// GetProcInfo groups the processes named in nameSet, all of them when it is empty, by name.
+// Only the processes kept have the files selected by options read.
+func GetProcInfo(nameSet map[string]bool, options uint32) map[string]map[int]*ProcInfo {
 	var ok bool
-	var rProcName string
 	pi := make(map[string]map[int]*ProcInfo)
-	go ScanProcFS(fdFlag)
-	for proc := range ProcInfoChan {
-		if proc.IsEnd {
-			return pi
-		}
-		// filter
-		if nameSet == nil || len(nameSet) == 0 {
-			goto assign
-		}
-		if _, ok = nameSet[proc.Stat.Name]; ok {
-			goto assign
-		}
-		if len(proc.Cmdline) == 0 {
-			continue
-		}
-		rProcName = strings.TrimPrefix(proc.Cmdline[0], "./")
-		if _, ok = nameSet[rProcName]; !ok {
+	procs, _, err := DefaultProcCache.ScanMatching(options, func(proc *ProcInfo) bool {
+		_, ok := procSetName(nameSet, proc)
+		return ok
+	})
+	if err != nil {
+		return pi
+	}
+	for _, proc := range procs {
+		name, matched := procSetName(nameSet, proc)
+		if !matched {
 			continue
 		}
-		proc.Stat.Name = rProcName
-
-	assign:
+		proc.Stat.Name = name
 		if _, ok = pi[proc.Stat.Name]; !ok {
 			pi[proc.Stat.Name] = make(map[int]*ProcInfo)
 		}
@@ -241,6 +308,19 @@ func GetProcInfo(nameSet map[string]bool, fdFlag bool) map[string]map[int]*ProcI
 	return pi
 }
 
+// procSetName tells whether proc is named in nameSet, by its comm or by its Cmdline[0] without "./",
+// and returns the name it matched. An empty nameSet matches every process by its comm.
+func procSetName(nameSet map[string]bool, proc *ProcInfo) (string, bool) {
+	if len(nameSet) == 0 || nameSet[proc.Stat.Name] {
+		return proc.Stat.Name, true
+	}
+	if len(proc.Cmdline) == 0 {
+		return "", false
+	}
+	name := strings.TrimPrefix(proc.Cmdline[0], "./")
+	return name, nameSet[name]
+}
+
 func CleanGlobalProcFds() {
 	var (
 		map2L map[uint32]Fd
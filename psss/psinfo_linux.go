@@ -0,0 +1,391 @@
+// +build linux
+
+package psss
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Options selecting the per-process files read by ScanProcFS, besides cmdline and stat.
+const (
+	ProcReadFds = 1 << iota
+	ProcReadStatus
+	ProcReadIO
+	ProcReadLimits
+	ProcReadLinks
+	ProcReadEnviron
+	ProcReadMemory
+	ProcReadCgroup
+	ProcReadThreads
+	ProcReadSchedstat
+	ProcReadOpenFiles
+	ProcReadMaps
+)
+
+var (
+	// EnvironRedactRegExp matches the names of environment variables whose values are replaced by RedactedValue.
+	// Set it to nil to keep all values.
+	EnvironRedactRegExp = regexp.MustCompile(`(?i)(pass|secret|token|key|credential|auth|cookie|session)`)
+	RedactedValue       = "[REDACTED]"
+)
+
+// definition comes from http://man7.org/linux/man-pages/man5/proc.5.html
+type ProcStatus struct {
+	Umask                    uint32
+	Tgid                     int
+	TracerPid                int
+	Uid                      [4]uint32 // real, effective, saved set and filesystem UIDs
+	Gid                      [4]uint32 // real, effective, saved set and filesystem GIDs
+	FDSize                   uint64    // number of file descriptor slots currently allocated
+	VmPeak                   uint64    // peak virtual memory size, in kB
+	VmSize                   uint64    // virtual memory size, in kB
+	VmLck                    uint64    // locked memory size, in kB
+	VmHWM                    uint64    // peak resident set size ("high water mark"), in kB
+	VmRSS                    uint64    // resident set size, in kB
+	VmData                   uint64    // size of data segment, in kB
+	VmStk                    uint64    // size of stack segment, in kB
+	VmSwap                   uint64    // swapped-out virtual memory size by anonymous private pages, in kB
+	Threads                  int64     // number of threads in process containing this thread
+	CapInh                   uint64    // mask of capabilities enabled in inheritable set
+	CapPrm                   uint64    // mask of capabilities enabled in permitted set
+	CapEff                   uint64    // mask of capabilities enabled in effective set
+	CapBnd                   uint64    // capability bounding set
+	CapAmb                   uint64    // ambient capability set (since Linux 4.3)
+	NoNewPrivs               int       // value of the no_new_privs bit (since Linux 4.10)
+	Seccomp                  int       // seccomp mode: 0 disabled, 1 strict, 2 filter (since Linux 3.8)
+	VoluntaryCtxtSwitches    uint64    // number of voluntary context switches (since Linux 2.6.23)
+	NonvoluntaryCtxtSwitches uint64    // number of involuntary context switches (since Linux 2.6.23)
+}
+
+func (ps *ProcStatus) Parse(raw []byte) (err error) {
+	var (
+		line  []byte
+		colon int
+		key   string
+		value string
+		i     int
+	)
+	for len(raw) > 0 {
+		if i = bytes.IndexByte(raw, '\n'); i < 0 {
+			line, raw = raw, nil
+		} else {
+			line, raw = raw[:i], raw[i+1:]
+		}
+		if colon = bytes.IndexByte(line, ':'); colon < 0 {
+			continue
+		}
+		key = string(line[:colon])
+		value = strings.TrimSuffix(strings.TrimSpace(string(line[colon+1:])), " kB")
+		switch key {
+		case "Umask":
+			var v uint64
+			v, err = strconv.ParseUint(value, 8, 32)
+			ps.Umask = uint32(v)
+		case "Tgid":
+			ps.Tgid, err = strconv.Atoi(value)
+		case "TracerPid":
+			ps.TracerPid, err = strconv.Atoi(value)
+		case "Uid":
+			err = parseIDs(value, &ps.Uid)
+		case "Gid":
+			err = parseIDs(value, &ps.Gid)
+		case "FDSize":
+			ps.FDSize, err = strconv.ParseUint(value, 10, 64)
+		case "VmPeak":
+			ps.VmPeak, err = strconv.ParseUint(value, 10, 64)
+		case "VmSize":
+			ps.VmSize, err = strconv.ParseUint(value, 10, 64)
+		case "VmLck":
+			ps.VmLck, err = strconv.ParseUint(value, 10, 64)
+		case "VmHWM":
+			ps.VmHWM, err = strconv.ParseUint(value, 10, 64)
+		case "VmRSS":
+			ps.VmRSS, err = strconv.ParseUint(value, 10, 64)
+		case "VmData":
+			ps.VmData, err = strconv.ParseUint(value, 10, 64)
+		case "VmStk":
+			ps.VmStk, err = strconv.ParseUint(value, 10, 64)
+		case "VmSwap":
+			ps.VmSwap, err = strconv.ParseUint(value, 10, 64)
+		case "Threads":
+			ps.Threads, err = strconv.ParseInt(value, 10, 64)
+		case "CapInh":
+			ps.CapInh, err = strconv.ParseUint(value, 16, 64)
+		case "CapPrm":
+			ps.CapPrm, err = strconv.ParseUint(value, 16, 64)
+		case "CapEff":
+			ps.CapEff, err = strconv.ParseUint(value, 16, 64)
+		case "CapBnd":
+			ps.CapBnd, err = strconv.ParseUint(value, 16, 64)
+		case "CapAmb":
+			ps.CapAmb, err = strconv.ParseUint(value, 16, 64)
+		case "NoNewPrivs":
+			ps.NoNewPrivs, err = strconv.Atoi(value)
+		case "Seccomp":
+			ps.Seccomp, err = strconv.Atoi(value)
+		case "voluntary_ctxt_switches":
+			ps.VoluntaryCtxtSwitches, err = strconv.ParseUint(value, 10, 64)
+		case "nonvoluntary_ctxt_switches":
+			ps.NonvoluntaryCtxtSwitches, err = strconv.ParseUint(value, 10, 64)
+		}
+		if err != nil {
+			return fmt.Errorf("parse field:[%s] error:[%v]", key, err)
+		}
+	}
+	return nil
+}
+
+func parseIDs(value string, ids *[4]uint32) error {
+	for i, s := range strings.Fields(value) {
+		if i >= len(ids) {
+			break
+		}
+		v, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return err
+		}
+		ids[i] = uint32(v)
+	}
+	return nil
+}
+
+// definition comes from http://man7.org/linux/man-pages/man5/proc.5.html
+type ProcIO struct {
+	Rchar               uint64 // bytes read by read(2) and similar, whether or not from storage
+	Wchar               uint64 // bytes written by write(2) and similar
+	Syscr               uint64 // read I/O operations
+	Syscw               uint64 // write I/O operations
+	ReadBytes           uint64 // bytes really fetched from the storage layer
+	WriteBytes          uint64 // bytes sent to the storage layer
+	CancelledWriteBytes uint64 // bytes whose writeback was cancelled by truncation
+}
+
+func (pio *ProcIO) Parse(raw []byte) (err error) {
+	var v uint64
+	for _, line := range strings.Split(string(raw), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+			return fmt.Errorf("parse field:[%s] error:[%v]", fields[0], err)
+		}
+		switch fields[0] {
+		case "rchar:":
+			pio.Rchar = v
+		case "wchar:":
+			pio.Wchar = v
+		case "syscr:":
+			pio.Syscr = v
+		case "syscw:":
+			pio.Syscw = v
+		case "read_bytes:":
+			pio.ReadBytes = v
+		case "write_bytes:":
+			pio.WriteBytes = v
+		case "cancelled_write_bytes:":
+			pio.CancelledWriteBytes = v
+		}
+	}
+	return nil
+}
+
+// Unlimited resource limits are reported as RlimitInfinity.
+const RlimitInfinity = math.MaxUint64
+
+type ProcLimit struct {
+	Soft  uint64
+	Hard  uint64
+	Units string
+}
+
+// definition comes from Linux kernel /fs/proc/base.c
+type ProcLimits struct {
+	CPUTime          ProcLimit // RLIMIT_CPU
+	FileSize         ProcLimit // RLIMIT_FSIZE
+	DataSize         ProcLimit // RLIMIT_DATA
+	StackSize        ProcLimit // RLIMIT_STACK
+	CoreFileSize     ProcLimit // RLIMIT_CORE
+	ResidentSet      ProcLimit // RLIMIT_RSS
+	Processes        ProcLimit // RLIMIT_NPROC
+	OpenFiles        ProcLimit // RLIMIT_NOFILE
+	LockedMemory     ProcLimit // RLIMIT_MEMLOCK
+	AddressSpace     ProcLimit // RLIMIT_AS
+	FileLocks        ProcLimit // RLIMIT_LOCKS
+	PendingSignals   ProcLimit // RLIMIT_SIGPENDING
+	MsgqueueSize     ProcLimit // RLIMIT_MSGQUEUE
+	NicePriority     ProcLimit // RLIMIT_NICE
+	RealtimePriority ProcLimit // RLIMIT_RTPRIO
+	RealtimeTimeout  ProcLimit // RLIMIT_RTTIME
+}
+
+func (pl *ProcLimits) Parse(raw []byte) (err error) {
+	for _, line := range strings.Split(string(raw), "\n") {
+		// columns are aligned, the name takes the first 26 characters
+		if len(line) < 26 || strings.HasPrefix(line, "Limit") {
+			continue
+		}
+		var limit *ProcLimit
+		switch strings.TrimSpace(line[:26]) {
+		case "Max cpu time":
+			limit = &pl.CPUTime
+		case "Max file size":
+			limit = &pl.FileSize
+		case "Max data size":
+			limit = &pl.DataSize
+		case "Max stack size":
+			limit = &pl.StackSize
+		case "Max core file size":
+			limit = &pl.CoreFileSize
+		case "Max resident set":
+			limit = &pl.ResidentSet
+		case "Max processes":
+			limit = &pl.Processes
+		case "Max open files":
+			limit = &pl.OpenFiles
+		case "Max locked memory":
+			limit = &pl.LockedMemory
+		case "Max address space":
+			limit = &pl.AddressSpace
+		case "Max file locks":
+			limit = &pl.FileLocks
+		case "Max pending signals":
+			limit = &pl.PendingSignals
+		case "Max msgqueue size":
+			limit = &pl.MsgqueueSize
+		case "Max nice priority":
+			limit = &pl.NicePriority
+		case "Max realtime priority":
+			limit = &pl.RealtimePriority
+		case "Max realtime timeout":
+			limit = &pl.RealtimeTimeout
+		default:
+			continue
+		}
+		fields := strings.Fields(line[26:])
+		if len(fields) < 2 {
+			return fmt.Errorf("line:[%s] too short", line)
+		}
+		if limit.Soft, err = parseLimit(fields[0]); err != nil {
+			return err
+		}
+		if limit.Hard, err = parseLimit(fields[1]); err != nil {
+			return err
+		}
+		if len(fields) > 2 {
+			limit.Units = fields[2]
+		}
+	}
+	return nil
+}
+
+func parseLimit(s string) (uint64, error) {
+	if s == "unlimited" {
+		return RlimitInfinity, nil
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// ParseEnviron splits the NUL separated content of /proc/[pid]/environ into KEY=value entries,
+// redacting the values whose names match EnvironRedactRegExp.
+func ParseEnviron(raw []byte) []string {
+	env := make([]string, 0, bytes.Count(raw, []byte{0}))
+	var i int
+	for len(raw) > 0 {
+		if i = bytes.IndexByte(raw, byte(0)); i < 0 {
+			i = len(raw)
+		}
+		if i > 0 {
+			env = append(env, RedactEnv(string(raw[:i])))
+		}
+		if i == len(raw) {
+			break
+		}
+		raw = raw[i+1:]
+	}
+	return env
+}
+
+func RedactEnv(kv string) string {
+	if EnvironRedactRegExp == nil {
+		return kv
+	}
+	eq := strings.IndexByte(kv, '=')
+	if eq < 0 || !EnvironRedactRegExp.MatchString(kv[:eq]) {
+		return kv
+	}
+	return kv[:eq+1] + RedactedValue
+}
+
+func (p *ProcInfo) readProcFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(ProcRoot + "/" + strconv.Itoa(p.Stat.Pid) + "/" + name)
+}
+
+func (p *ProcInfo) GetStatus() error {
+	raw, err := p.readProcFile("status")
+	if err != nil {
+		return err
+	}
+	p.Status = new(ProcStatus)
+	return p.Status.Parse(raw)
+}
+
+func (p *ProcInfo) GetIO() error {
+	raw, err := p.readProcFile("io")
+	if err != nil {
+		return err
+	}
+	p.IO = new(ProcIO)
+	return p.IO.Parse(raw)
+}
+
+func (p *ProcInfo) GetLimits() error {
+	raw, err := p.readProcFile("limits")
+	if err != nil {
+		return err
+	}
+	p.Limits = new(ProcLimits)
+	return p.Limits.Parse(raw)
+}
+
+// GetOwner reads the owner of /proc/[pid], the effective uid of the process, as the scanner does.
+func (p *ProcInfo) GetOwner() error {
+	var st syscall.Stat_t
+	if err := syscall.Stat(ProcRoot+"/"+strconv.Itoa(p.Stat.Pid), &st); err != nil {
+		return err
+	}
+	p.UID = st.Uid
+	return nil
+}
+
+// GetLinks reads the exe, cwd and root symlinks, which are only readable for processes we may ptrace.
+func (p *ProcInfo) GetLinks() (err error) {
+	path := ProcRoot + "/" + strconv.Itoa(p.Stat.Pid) + "/"
+	if p.Exe, err = os.Readlink(path + "exe"); err != nil {
+		return err
+	}
+	if p.Cwd, err = os.Readlink(path + "cwd"); err != nil {
+		return err
+	}
+	if p.Root, err = os.Readlink(path + "root"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (p *ProcInfo) GetEnviron() error {
+	raw, err := p.readProcFile("environ")
+	if err != nil {
+		return err
+	}
+	p.Environ = ParseEnviron(raw)
+	return nil
+}
@@ -0,0 +1,131 @@
+// +build linux
+
+package psss
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+// ProcMemory is the memory of a process accounted page by page, all values in kB.
+// Unlike ProcStat.Rss, pages shared with other processes are divided among them in Pss.
+// definition comes from http://man7.org/linux/man-pages/man5/proc.5.html
+type ProcMemory struct {
+	Rss          uint64 // resident memory, shared pages counted in full
+	Pss          uint64 // proportional set size: each shared page divided by the number of processes mapping it
+	PssAnon      uint64
+	PssFile      uint64
+	PssShmem     uint64
+	SharedClean  uint64
+	SharedDirty  uint64
+	PrivateClean uint64
+	PrivateDirty uint64
+	Swap         uint64 // anonymous memory swapped out
+	SwapPss      uint64 // proportional swap share, shared swap pages divided like Pss
+}
+
+// Uss is the unique set size, the memory freed if the process exited.
+func (pm *ProcMemory) Uss() uint64 {
+	return pm.PrivateClean + pm.PrivateDirty
+}
+
+func (pm *ProcMemory) Shared() uint64 {
+	return pm.SharedClean + pm.SharedDirty
+}
+
+func (pm *ProcMemory) Add(other *ProcMemory) {
+	pm.Rss += other.Rss
+	pm.Pss += other.Pss
+	pm.PssAnon += other.PssAnon
+	pm.PssFile += other.PssFile
+	pm.PssShmem += other.PssShmem
+	pm.SharedClean += other.SharedClean
+	pm.SharedDirty += other.SharedDirty
+	pm.PrivateClean += other.PrivateClean
+	pm.PrivateDirty += other.PrivateDirty
+	pm.Swap += other.Swap
+	pm.SwapPss += other.SwapPss
+}
+
+// Parse sums the "Key: value kB" lines of either smaps_rollup or smaps,
+// the latter holding one block per mapping.
+func (pm *ProcMemory) Parse(raw []byte) error {
+	var (
+		line   []byte
+		fields [][]byte
+		v      uint64
+		ok     bool
+		i      int
+	)
+	for len(raw) > 0 {
+		if i = bytes.IndexByte(raw, '\n'); i < 0 {
+			line, raw = raw, nil
+		} else {
+			line, raw = raw[:i], raw[i+1:]
+		}
+		fields = bytes.Fields(line)
+		if len(fields) != 3 || string(fields[2]) != "kB" {
+			continue
+		}
+		if v, ok = parseDecimal(fields[1]); !ok {
+			return fmt.Errorf("parse field:[%s] error:[invalid value %s]", fields[0], fields[1])
+		}
+		switch string(fields[0]) {
+		case "Rss:":
+			pm.Rss += v
+		case "Pss:":
+			pm.Pss += v
+		case "Pss_Anon:":
+			pm.PssAnon += v
+		case "Pss_File:":
+			pm.PssFile += v
+		case "Pss_Shmem:":
+			pm.PssShmem += v
+		case "Shared_Clean:":
+			pm.SharedClean += v
+		case "Shared_Dirty:":
+			pm.SharedDirty += v
+		case "Private_Clean:":
+			pm.PrivateClean += v
+		case "Private_Dirty:":
+			pm.PrivateDirty += v
+		case "Swap:":
+			pm.Swap += v
+		case "SwapPss:":
+			pm.SwapPss += v
+		}
+	}
+	return nil
+}
+
+// GetMemory reads /proc/[pid]/smaps_rollup, or smaps on kernels before 4.14.
+func (p *ProcInfo) GetMemory() error {
+	raw, err := p.readProcFile("smaps_rollup")
+	if os.IsNotExist(err) {
+		raw, err = p.readProcFile("smaps")
+	}
+	if err != nil {
+		return err
+	}
+	p.Memory = new(ProcMemory)
+	return p.Memory.Parse(raw)
+}
+
+// ServiceMemory sums the memory of all the processes of every service, as grouped by GetProcInfo.
+// Processes without Memory, not scanned with ProcReadMemory or not readable, are skipped.
+func ServiceMemory(pi map[string]map[int]*ProcInfo) map[string]*ProcMemory {
+	sm := make(map[string]*ProcMemory)
+	for name, procs := range pi {
+		for _, proc := range procs {
+			if proc.Memory == nil {
+				continue
+			}
+			if _, ok := sm[name]; !ok {
+				sm[name] = new(ProcMemory)
+			}
+			sm[name].Add(proc.Memory)
+		}
+	}
+	return sm
+}
@@ -0,0 +1,150 @@
+package psss
+
+import (
+	"sort"
+)
+
+// ProcessTree links the processes of a scan through their parent pids.
+type ProcessTree struct {
+	Procs    map[int]*ProcInfo
+	Roots    []int                // processes whose parent is not part of the scan, such as init and kthreadd
+	Children map[int][]int        // sorted by pid
+	Sockets  map[int][]SocketInfo // filled by AttachSockets
+}
+
+func NewProcessTree(procs []*ProcInfo) *ProcessTree {
+	t := new(ProcessTree)
+	t.Procs = make(map[int]*ProcInfo, len(procs))
+	t.Children = make(map[int][]int)
+	t.Sockets = make(map[int][]SocketInfo)
+	for _, proc := range procs {
+		t.Procs[proc.Stat.Pid] = proc
+	}
+	for pid, proc := range t.Procs {
+		if _, ok := t.Procs[proc.Stat.Ppid]; !ok || proc.Stat.Ppid == pid {
+			t.Roots = append(t.Roots, pid)
+			continue
+		}
+		t.Children[proc.Stat.Ppid] = append(t.Children[proc.Stat.Ppid], pid)
+	}
+	sort.Ints(t.Roots)
+	for _, children := range t.Children {
+		sort.Ints(children)
+	}
+	return t
+}
+
+// AttachSockets assigns every socket to the processes holding an fd on its inode.
+// The processes must have been scanned with ProcReadFds.
+func (t *ProcessTree) AttachSockets(sis map[uint32]SocketInfo) {
+	for pid, proc := range t.Procs {
+		for inode := range proc.Fds {
+			if si, ok := sis[inode]; ok {
+				t.Sockets[pid] = append(t.Sockets[pid], si)
+			}
+		}
+	}
+}
+
+// ProcFdIndex maps a socket or file inode to the processes holding an fd on it.
+type ProcFdIndex map[uint32][]*ProcInfo
+
+// NewProcFdIndex indexes the fds of procs, which must have been scanned with ProcReadFds.
+func NewProcFdIndex(procs []*ProcInfo) ProcFdIndex {
+	index := make(ProcFdIndex)
+	for _, proc := range procs {
+		for inode := range proc.Fds {
+			index[inode] = append(index[inode], proc)
+		}
+	}
+	return index
+}
+
+func (t *ProcessTree) ChildrenOf(pid int) []*ProcInfo {
+	procs := make([]*ProcInfo, 0, len(t.Children[pid]))
+	for _, child := range t.Children[pid] {
+		procs = append(procs, t.Procs[child])
+	}
+	return procs
+}
+
+// Ancestors returns the parent of pid first, up to the root of its tree.
+func (t *ProcessTree) Ancestors(pid int) []*ProcInfo {
+	procs := make([]*ProcInfo, 0)
+	proc, ok := t.Procs[pid]
+	for ok {
+		if proc, ok = t.Procs[proc.Stat.Ppid]; !ok || proc.Stat.Pid == pid {
+			break
+		}
+		procs = append(procs, proc)
+	}
+	return procs
+}
+
+// Subtree returns pid followed by all its descendants, depth first.
+func (t *ProcessTree) Subtree(pid int) []*ProcInfo {
+	procs := make([]*ProcInfo, 0)
+	t.Walk(pid, func(proc *ProcInfo, depth int) {
+		procs = append(procs, proc)
+	})
+	return procs
+}
+
+// Walk calls fn for pid and its descendants depth first, depth being 0 for pid itself.
+func (t *ProcessTree) Walk(pid int, fn func(proc *ProcInfo, depth int)) {
+	t.walk(pid, 0, fn)
+}
+
+func (t *ProcessTree) walk(pid, depth int, fn func(proc *ProcInfo, depth int)) {
+	proc, ok := t.Procs[pid]
+	if !ok {
+		return
+	}
+	fn(proc, depth)
+	for _, child := range t.Children[pid] {
+		t.walk(child, depth+1, fn)
+	}
+}
+
+// Sessions groups the processes by session id.
+func (t *ProcessTree) Sessions() map[int][]*ProcInfo {
+	sessions := make(map[int][]*ProcInfo)
+	for _, proc := range t.Procs {
+		sessions[proc.Stat.Session] = append(sessions[proc.Stat.Session], proc)
+	}
+	return sessions
+}
+
+// ProcessGroups groups the processes by process group id.
+func (t *ProcessTree) ProcessGroups() map[int][]*ProcInfo {
+	groups := make(map[int][]*ProcInfo)
+	for _, proc := range t.Procs {
+		groups[proc.Stat.Pgrp] = append(groups[proc.Stat.Pgrp], proc)
+	}
+	return groups
+}
+
+type ProcTotals struct {
+	Procs   int
+	Threads int64
+	Utime   uint64 // clock ticks
+	Stime   uint64 // clock ticks
+	Rss     int64  // pages
+	Fds     int
+	Sockets int
+}
+
+// SubtreeTotals sums the resources of pid and all its descendants.
+func (t *ProcessTree) SubtreeTotals(pid int) *ProcTotals {
+	pt := new(ProcTotals)
+	t.Walk(pid, func(proc *ProcInfo, depth int) {
+		pt.Procs++
+		pt.Threads += proc.Stat.NumThreads
+		pt.Utime += proc.Stat.Utime
+		pt.Stime += proc.Stat.Stime
+		pt.Rss += proc.Stat.Rss
+		pt.Fds += proc.NumFds
+		pt.Sockets += len(t.Sockets[proc.Stat.Pid])
+	})
+	return pt
+}
@@ -0,0 +1,632 @@
+// +build linux
+
+package psss
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Flags of /proc/net/route and /proc/net/arp, from linux/route.h and linux/if_arp.h.
+const (
+	procRouteFlagUp      = 0x1
+	procRouteFlagGateway = 0x2
+	procRouteFlagReject  = 0x200
+	procArpFlagComplete  = 0x2
+	procArpFlagPermanent = 0x4
+)
+
+var (
+	// RouteTypes names the RTN_* values of Route.Type.
+	RouteTypes = []string{"unspec", "unicast", "local", "broadcast", "anycast", "multicast", "blackhole", "unreachable", "prohibit", "throw", "nat", "xresolve"}
+
+	// NeighbourStates names the NUD_* bits of Neighbour.State, as ip neigh does.
+	NeighbourStates = map[uint16]string{
+		unix.NUD_INCOMPLETE: "INCOMPLETE",
+		unix.NUD_REACHABLE:  "REACHABLE",
+		unix.NUD_STALE:      "STALE",
+		unix.NUD_DELAY:      "DELAY",
+		unix.NUD_PROBE:      "PROBE",
+		unix.NUD_FAILED:     "FAILED",
+		unix.NUD_NOARP:      "NOARP",
+		unix.NUD_PERMANENT:  "PERMANENT",
+	}
+)
+
+// RouteTableName names the reserved routing tables, other tables are named by their ID as in /etc/iproute2/rt_tables.
+func RouteTableName(table uint32) string {
+	switch table {
+	case unix.RT_TABLE_LOCAL:
+		return "local"
+	case unix.RT_TABLE_MAIN:
+		return "main"
+	case unix.RT_TABLE_DEFAULT:
+		return "default"
+	}
+	return strconv.FormatUint(uint64(table), 10)
+}
+
+// NextHop is a path of a multipath route.
+type NextHop struct {
+	Gateway  net.IP
+	OutIndex int
+	OutIf    string
+	Weight   int // hops+1, as ip route shows it
+}
+
+// Route is an entry of a routing table, from RTM_GETROUTE or /proc/net/route.
+type Route struct {
+	Family   uint8
+	Table    uint32
+	Type     uint8 // RTN_*, see RouteTypes
+	Protocol uint8 // RTPROT_*, who installed the route: kernel, boot, static, dhcp...
+	Scope    uint8
+	Dst      *net.IPNet // 0.0.0.0/0 or ::/0 for the default route
+	Src      net.IP     // preferred source address, may be nil
+	Gateway  net.IP     // nil for directly connected destinations
+	OutIndex int
+	OutIf    string
+	Priority uint32     // the metric
+	NextHops []*NextHop // for multipath routes, the first one is also held by Gateway and OutIf
+}
+
+func (r *Route) TypeString() string {
+	if int(r.Type) < len(RouteTypes) {
+		return RouteTypes[r.Type]
+	}
+	return strconv.Itoa(int(r.Type))
+}
+
+// Routes are the routes of all the tables of the network namespace of the caller.
+type Routes []*Route
+
+// Rule is a policy routing rule, from RTM_GETRULE. Rules are evaluated by increasing Priority.
+type Rule struct {
+	Family   uint8
+	Priority uint32
+	Table    uint32
+	Action   uint8 // FR_ACT_*
+	Goto     uint32
+	Invert   bool
+	Tos      uint8
+	Src      *net.IPNet
+	Dst      *net.IPNet
+	IifName  string
+	OifName  string
+	Fwmark   uint32
+	FwMask   uint32
+	// SuppressPrefixLen rejects the routes the rule finds with a prefix no longer than it, such as the default
+	// route with 0 as in ip rule add table main suppress_prefixlength 0; -1 when unset.
+	SuppressPrefixLen int
+	SuppressIfGroup   int // rejects the routes through the devices of this group, -1 when unset
+	// Others tells the rule has selectors Lookup does not evaluate, such as ports, uid ranges or l3mdev.
+	Others bool
+}
+
+type Rules []*Rule
+
+// RoutingTables are the routes and the rules choosing among their tables.
+type RoutingTables struct {
+	Routes Routes
+	Rules  Rules
+	// FromProc tells the routes were read from /proc/net/route as rtnetlink failed:
+	// IPv4 routes of the main table only, and no rules.
+	FromProc bool
+}
+
+func NewRoutingTables() *RoutingTables {
+	return &RoutingTables{Routes: make(Routes, 0), Rules: make(Rules, 0)}
+}
+
+// Get dumps the routes of all tables and the rules over rtnetlink, or reads /proc/net/route when that fails.
+func (rt *RoutingTables) Get() error {
+	if err := rt.getNetlink(); err != nil {
+		rt.Routes, rt.Rules = make(Routes, 0), make(Rules, 0)
+		if procErr := rt.ReadProcRoute(); procErr != nil {
+			return fmt.Errorf("netlink error:[%v], proc error:[%v]", err, procErr)
+		}
+		rt.FromProc = true
+	}
+	return nil
+}
+
+func (rt *RoutingTables) getNetlink() error {
+	names := currentLinkNames()
+	msgs, err := netlinkRouteDump(unix.RTM_GETROUTE, unix.AF_UNSPEC)
+	if err != nil {
+		return fmt.Errorf("dump routes error:[%v]", err)
+	}
+	for _, m := range msgs {
+		if m.Header.Type != unix.RTM_NEWROUTE {
+			continue
+		}
+		r, err := parseRoute(&m, names)
+		if err != nil {
+			return err
+		}
+		if r != nil {
+			rt.Routes = append(rt.Routes, r)
+		}
+	}
+
+	if msgs, err = netlinkRouteDump(unix.RTM_GETRULE, unix.AF_UNSPEC); err != nil {
+		return fmt.Errorf("dump rules error:[%v]", err)
+	}
+	for _, m := range msgs {
+		if m.Header.Type != unix.RTM_NEWRULE {
+			continue
+		}
+		r, err := parseRule(&m)
+		if err != nil {
+			return err
+		}
+		rt.Rules = append(rt.Rules, r)
+	}
+	sort.SliceStable(rt.Rules, func(i, j int) bool {
+		return rt.Rules[i].Priority < rt.Rules[j].Priority
+	})
+	return nil
+}
+
+// ReadProcRoute reads the IPv4 routes of the main table from /proc/net/route.
+func (rt *RoutingTables) ReadProcRoute() error {
+	raw, err := ioutil.ReadFile(ProcRoot + "/net/route")
+	if err != nil {
+		return err
+	}
+	return rt.ParseProcRoute(raw)
+}
+
+// ParseProcRoute parses /proc/net/route: Iface Destination Gateway Flags RefCnt Use Metric Mask MTU Window IRTT,
+// the addresses being written in hex in host byte order.
+func (rt *RoutingTables) ParseProcRoute(raw []byte) error {
+	names := currentLinkIndexes()
+	for i, line := range strings.Split(strings.TrimSpace(string(raw)), "\n") {
+		fields := strings.Fields(line)
+		if i == 0 || len(fields) < 8 {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil {
+			return fmt.Errorf("parse flags of line:[%s] error:[%v]", line, err)
+		}
+		if flags&procRouteFlagUp == 0 {
+			continue
+		}
+		dst, err := procHexToIPv4(fields[1])
+		if err != nil {
+			return err
+		}
+		mask, err := procHexToIPv4(fields[7])
+		if err != nil {
+			return err
+		}
+		metric, err := strconv.ParseUint(fields[6], 10, 32)
+		if err != nil {
+			return fmt.Errorf("parse metric of line:[%s] error:[%v]", line, err)
+		}
+		r := &Route{
+			Family:   unix.AF_INET,
+			Table:    unix.RT_TABLE_MAIN,
+			Type:     unix.RTN_UNICAST,
+			Scope:    unix.RT_SCOPE_LINK,
+			Dst:      &net.IPNet{IP: dst, Mask: net.IPMask(mask)},
+			OutIndex: names[fields[0]],
+			OutIf:    fields[0],
+			Priority: uint32(metric),
+		}
+		if flags&procRouteFlagGateway != 0 {
+			if r.Gateway, err = procHexToIPv4(fields[2]); err != nil {
+				return err
+			}
+			r.Scope = unix.RT_SCOPE_UNIVERSE
+		}
+		if flags&procRouteFlagReject != 0 {
+			r.Type = unix.RTN_UNREACHABLE
+		}
+		rt.Routes = append(rt.Routes, r)
+	}
+	return nil
+}
+
+// Lookup finds the route the kernel would send a packet to dst with, evaluating the rules the way fib_rules does.
+// src is the local address of the socket, nil when unknown. Packets are taken as unmarked, as are those of sockets
+// without SO_MARK, so fwmark rules only match inverted, as in the not fwmark rule of WireGuard. Rules matching on oif,
+// tos or other selectors which the addresses alone do not tell are skipped, iif rules only match lo, the iif of
+// locally generated packets.
+// IPv4-mapped IPv6 addresses are looked up as IPv4.
+func (rt *RoutingTables) Lookup(src, dst net.IP) (*Route, error) {
+	family := uint8(unix.AF_INET6)
+	if ip4 := dst.To4(); ip4 != nil {
+		family, dst = unix.AF_INET, ip4
+		if src != nil {
+			src = src.To4()
+		}
+	}
+
+	rules := make(Rules, 0)
+	for _, rule := range rt.Rules {
+		if rule.Family == family {
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) == 0 {
+		// the rules every namespace starts with
+		for _, table := range []uint32{unix.RT_TABLE_LOCAL, unix.RT_TABLE_MAIN, unix.RT_TABLE_DEFAULT} {
+			rules = append(rules, &Rule{Family: family, Table: table, Action: unix.FR_ACT_TO_TBL, SuppressPrefixLen: -1, SuppressIfGroup: -1})
+		}
+	}
+
+	var gotoPriority uint32
+	for _, rule := range rules {
+		if rule.Priority < gotoPriority || !rule.match(src, dst) {
+			continue
+		}
+		switch rule.Action {
+		case unix.FR_ACT_TO_TBL:
+			r := rt.Routes.lookupTable(family, rule.Table, dst)
+			if r == nil || r.Type == unix.RTN_THROW {
+				continue
+			}
+			// fib_rules suppress the result and go on with the next rule
+			if ones, _ := r.Dst.Mask.Size(); ones <= rule.SuppressPrefixLen {
+				continue
+			}
+			switch r.Type {
+			case unix.RTN_BLACKHOLE, unix.RTN_UNREACHABLE, unix.RTN_PROHIBIT:
+				return r, fmt.Errorf("%s is %s by table:[%s]", dst, r.TypeString(), RouteTableName(rule.Table))
+			}
+			return r, nil
+		case unix.FR_ACT_GOTO:
+			gotoPriority = rule.Goto
+		case unix.FR_ACT_BLACKHOLE:
+			return nil, fmt.Errorf("%s is blackhole by rule:[%d]", dst, rule.Priority)
+		case unix.FR_ACT_UNREACHABLE:
+			return nil, fmt.Errorf("%s is unreachable by rule:[%d]", dst, rule.Priority)
+		case unix.FR_ACT_PROHIBIT:
+			return nil, fmt.Errorf("%s is prohibit by rule:[%d]", dst, rule.Priority)
+		}
+	}
+	return nil, fmt.Errorf("no route to %s", dst)
+}
+
+func (rule *Rule) match(src, dst net.IP) bool {
+	if rule.Others || rule.Tos != 0 || len(rule.OifName) > 0 {
+		return false
+	}
+	matched := true
+	if rule.Fwmark != 0 || rule.FwMask != 0 {
+		matched = false
+	}
+	if len(rule.IifName) > 0 && rule.IifName != "lo" {
+		matched = false
+	}
+	if rule.Src != nil && (src == nil || !rule.Src.Contains(src)) {
+		matched = false
+	}
+	if rule.Dst != nil && !rule.Dst.Contains(dst) {
+		matched = false
+	}
+	return matched != rule.Invert
+}
+
+// lookupTable returns the longest prefix match of table, the lowest metric among equal prefixes.
+func (rs Routes) lookupTable(family uint8, table uint32, dst net.IP) *Route {
+	var best *Route
+	bestLen := -1
+	for _, r := range rs {
+		if r.Family != family || r.Table != table || !r.Dst.Contains(dst) {
+			continue
+		}
+		ones, _ := r.Dst.Mask.Size()
+		if ones > bestLen || (ones == bestLen && r.Priority < best.Priority) {
+			best, bestLen = r, ones
+		}
+	}
+	return best
+}
+
+func parseRoute(m *syscall.NetlinkMessage, names map[int]string) (*Route, error) {
+	if len(m.Data) < unix.SizeofRtMsg {
+		return nil, fmt.Errorf("route message too short:[%d]", len(m.Data))
+	}
+	msg := (*unix.RtMsg)(unsafe.Pointer(&m.Data[0]))
+	// cached routes, such as IPv6 PMTU exceptions, are not part of the tables
+	if msg.Flags&unix.RTM_F_CLONED != 0 {
+		return nil, nil
+	}
+	bits := 8 * net.IPv4len
+	if msg.Family == unix.AF_INET6 {
+		bits = 8 * net.IPv6len
+	} else if msg.Family != unix.AF_INET {
+		return nil, nil
+	}
+	r := &Route{
+		Family:   msg.Family,
+		Table:    uint32(msg.Table),
+		Type:     msg.Type,
+		Protocol: msg.Protocol,
+		Scope:    msg.Scope,
+		Dst:      &net.IPNet{IP: make(net.IP, bits/8), Mask: net.CIDRMask(int(msg.Dst_len), bits)},
+	}
+	attrs, err := syscall.ParseNetlinkRouteAttr(m)
+	if err != nil {
+		return nil, err
+	}
+	for _, attr := range attrs {
+		switch attr.Attr.Type {
+		case unix.RTA_TABLE:
+			r.Table = nativeUint32(attr.Value)
+		case unix.RTA_DST:
+			r.Dst.IP = append(net.IP(nil), attr.Value...)
+		case unix.RTA_PREFSRC:
+			r.Src = append(net.IP(nil), attr.Value...)
+		case unix.RTA_GATEWAY:
+			r.Gateway = append(net.IP(nil), attr.Value...)
+		case unix.RTA_OIF:
+			r.OutIndex = int(nativeUint32(attr.Value))
+		case unix.RTA_PRIORITY:
+			r.Priority = nativeUint32(attr.Value)
+		case unix.RTA_MULTIPATH:
+			r.NextHops = parseNextHops(attr.Value, names)
+		}
+	}
+	if r.OutIndex != 0 {
+		r.OutIf = names[r.OutIndex]
+	}
+	if len(r.NextHops) > 0 && r.OutIndex == 0 {
+		r.Gateway, r.OutIndex, r.OutIf = r.NextHops[0].Gateway, r.NextHops[0].OutIndex, r.NextHops[0].OutIf
+	}
+	return r, nil
+}
+
+// parseNextHops parses the rtnexthop structs of RTA_MULTIPATH, each followed by its own attributes.
+func parseNextHops(b []byte, names map[int]string) []*NextHop {
+	nhs := make([]*NextHop, 0)
+	for len(b) >= unix.SizeofRtNexthop {
+		rtnh := (*unix.RtNexthop)(unsafe.Pointer(&b[0]))
+		if int(rtnh.Len) < unix.SizeofRtNexthop || int(rtnh.Len) > len(b) {
+			break
+		}
+		nh := &NextHop{OutIndex: int(rtnh.Ifindex), OutIf: names[int(rtnh.Ifindex)], Weight: int(rtnh.Hops) + 1}
+		for _, attr := range parseNestedAttrs(b[unix.SizeofRtNexthop:rtnh.Len]) {
+			if attr.Attr.Type == unix.RTA_GATEWAY {
+				nh.Gateway = append(net.IP(nil), attr.Value...)
+			}
+		}
+		nhs = append(nhs, nh)
+		aligned := (int(rtnh.Len) + unix.NLA_ALIGNTO - 1) &^ (unix.NLA_ALIGNTO - 1)
+		if aligned > len(b) {
+			break
+		}
+		b = b[aligned:]
+	}
+	return nhs
+}
+
+func parseRule(m *syscall.NetlinkMessage) (*Rule, error) {
+	if len(m.Data) < unix.SizeofRtMsg {
+		return nil, fmt.Errorf("rule message too short:[%d]", len(m.Data))
+	}
+	// struct fib_rule_hdr has the layout of struct rtmsg, the action taking the place of the type
+	hdr := (*unix.RtMsg)(unsafe.Pointer(&m.Data[0]))
+	bits := 8 * net.IPv4len
+	if hdr.Family == unix.AF_INET6 {
+		bits = 8 * net.IPv6len
+	}
+	rule := &Rule{
+		Family: hdr.Family,
+		Table:  uint32(hdr.Table),
+		Action: hdr.Type,
+		Invert: hdr.Flags&unix.FIB_RULE_INVERT != 0,
+		Tos:    hdr.Tos,
+		// the kernel leaves out or sends 0xffffffff the attributes it considers unset
+		SuppressPrefixLen: -1,
+		SuppressIfGroup:   -1,
+	}
+	// syscall.ParseNetlinkRouteAttr only knows link, address and route messages
+	for _, attr := range parseNestedAttrs(m.Data[unix.SizeofRtMsg:]) {
+		switch attr.Attr.Type {
+		case unix.FRA_PRIORITY:
+			rule.Priority = nativeUint32(attr.Value)
+		case unix.FRA_TABLE:
+			rule.Table = nativeUint32(attr.Value)
+		case unix.FRA_GOTO:
+			rule.Goto = nativeUint32(attr.Value)
+		case unix.FRA_SRC:
+			rule.Src = &net.IPNet{IP: append(net.IP(nil), attr.Value...), Mask: net.CIDRMask(int(hdr.Src_len), bits)}
+		case unix.FRA_DST:
+			rule.Dst = &net.IPNet{IP: append(net.IP(nil), attr.Value...), Mask: net.CIDRMask(int(hdr.Dst_len), bits)}
+		case unix.FRA_IIFNAME:
+			rule.IifName = strings.TrimRight(string(attr.Value), "\x00")
+		case unix.FRA_OIFNAME:
+			rule.OifName = strings.TrimRight(string(attr.Value), "\x00")
+		case unix.FRA_FWMARK:
+			rule.Fwmark = nativeUint32(attr.Value)
+		case unix.FRA_FWMASK:
+			rule.FwMask = nativeUint32(attr.Value)
+		case unix.FRA_SUPPRESS_PREFIXLEN:
+			rule.SuppressPrefixLen = int(int32(nativeUint32(attr.Value)))
+		case unix.FRA_SUPPRESS_IFGROUP:
+			// the groups of the devices are not read, the rule is not evaluated
+			if rule.SuppressIfGroup = int(int32(nativeUint32(attr.Value))); rule.SuppressIfGroup != -1 {
+				rule.Others = true
+			}
+		case unix.FRA_L3MDEV, unix.FRA_UID_RANGE, unix.FRA_IP_PROTO, unix.FRA_SPORT_RANGE, unix.FRA_DPORT_RANGE:
+			rule.Others = true
+		}
+	}
+	return rule, nil
+}
+
+// Neighbour is an entry of the ARP or NDP table, from RTM_GETNEIGH or /proc/net/arp.
+type Neighbour struct {
+	Family       uint8
+	IP           net.IP
+	HardwareAddr net.HardwareAddr // nil while unresolved
+	Index        int
+	Interface    string
+	State        uint16 // NUD_* bits, see NeighbourStates
+	Flags        uint8  // NTF_*, such as NTF_ROUTER
+}
+
+// StateString names the state bits as ip neigh does, such as REACHABLE or STALE.
+func (n *Neighbour) StateString() string {
+	if n.State == unix.NUD_NONE {
+		return "NONE"
+	}
+	states := make([]string, 0, 1)
+	for bit := uint16(1); bit != 0 && bit <= n.State; bit <<= 1 {
+		if n.State&bit != 0 {
+			states = append(states, NeighbourStates[bit])
+		}
+	}
+	return strings.Join(states, ",")
+}
+
+// Neighbours are the ARP and NDP entries of the network namespace of the caller.
+type Neighbours []*Neighbour
+
+func NewNeighbours() Neighbours {
+	return make(Neighbours, 0)
+}
+
+// Get dumps the neighbours over rtnetlink, or reads the IPv4 ones from /proc/net/arp when that fails.
+func (ns *Neighbours) Get() error {
+	err := ns.getNetlink()
+	if err == nil {
+		return nil
+	}
+	*ns = (*ns)[:0]
+	if procErr := ns.ReadProcArp(); procErr != nil {
+		return fmt.Errorf("netlink error:[%v], proc error:[%v]", err, procErr)
+	}
+	return nil
+}
+
+func (ns *Neighbours) getNetlink() error {
+	names := currentLinkNames()
+	msgs, err := netlinkRouteDump(unix.RTM_GETNEIGH, unix.AF_UNSPEC)
+	if err != nil {
+		return fmt.Errorf("dump neighbours error:[%v]", err)
+	}
+	for _, m := range msgs {
+		if m.Header.Type != unix.RTM_NEWNEIGH {
+			continue
+		}
+		if len(m.Data) < unix.SizeofNdMsg {
+			return fmt.Errorf("neighbour message too short:[%d]", len(m.Data))
+		}
+		msg := (*unix.NdMsg)(unsafe.Pointer(&m.Data[0]))
+		if msg.Family != unix.AF_INET && msg.Family != unix.AF_INET6 {
+			continue
+		}
+		n := &Neighbour{
+			Family:    msg.Family,
+			Index:     int(msg.Ifindex),
+			Interface: names[int(msg.Ifindex)],
+			State:     msg.State,
+			Flags:     msg.Flags,
+		}
+		for _, attr := range parseNestedAttrs(m.Data[unix.SizeofNdMsg:]) {
+			switch attr.Attr.Type {
+			case unix.NDA_DST:
+				n.IP = append(net.IP(nil), attr.Value...)
+			case unix.NDA_LLADDR:
+				n.HardwareAddr = append(net.HardwareAddr(nil), attr.Value...)
+			}
+		}
+		*ns = append(*ns, n)
+	}
+	return nil
+}
+
+// ReadProcArp reads the IPv4 neighbours from /proc/net/arp.
+func (ns *Neighbours) ReadProcArp() error {
+	raw, err := ioutil.ReadFile(ProcRoot + "/net/arp")
+	if err != nil {
+		return err
+	}
+	return ns.ParseProcArp(raw)
+}
+
+// ParseProcArp parses /proc/net/arp: IP address, HW type, Flags, HW address, Mask, Device.
+// Its flags only tell complete, permanent or incomplete entries apart.
+func (ns *Neighbours) ParseProcArp(raw []byte) error {
+	indexes := currentLinkIndexes()
+	for i, line := range strings.Split(strings.TrimSpace(string(raw)), "\n") {
+		fields := strings.Fields(line)
+		if i == 0 || len(fields) < 6 {
+			continue
+		}
+		flags, err := strconv.ParseUint(strings.TrimPrefix(fields[2], "0x"), 16, 32)
+		if err != nil {
+			return fmt.Errorf("parse flags of line:[%s] error:[%v]", line, err)
+		}
+		n := &Neighbour{
+			Family:    unix.AF_INET,
+			IP:        net.ParseIP(fields[0]).To4(),
+			Index:     indexes[fields[5]],
+			Interface: fields[5],
+			State:     unix.NUD_INCOMPLETE,
+		}
+		switch {
+		case flags&procArpFlagPermanent != 0:
+			n.State = unix.NUD_PERMANENT
+		case flags&procArpFlagComplete != 0:
+			n.State = unix.NUD_REACHABLE
+		}
+		if n.State != unix.NUD_INCOMPLETE {
+			n.HardwareAddr, _ = net.ParseMAC(fields[3])
+		}
+		*ns = append(*ns, n)
+	}
+	return nil
+}
+
+// Lookup finds the neighbour entry of ip on the interface of index, any interface when index is 0.
+func (ns Neighbours) Lookup(ip net.IP, index int) *Neighbour {
+	for _, n := range ns {
+		if n.IP.Equal(ip) && (index == 0 || n.Index == index) {
+			return n
+		}
+	}
+	return nil
+}
+
+func procHexToIPv4(hex string) (net.IP, error) {
+	ip, err := IPv4HexToString(hex)
+	if err != nil {
+		return nil, err
+	}
+	return net.ParseIP(ip).To4(), nil
+}
+
+// currentLinkNames maps the indexes of the interfaces to their names as of now, see also LinkName.
+func currentLinkNames() map[int]string {
+	names := make(map[int]string)
+	ls := NewLinks()
+	if err := ls.Get(); err != nil {
+		return names
+	}
+	for i, l := range ls {
+		names[i] = l.Name
+	}
+	return names
+}
+
+func currentLinkIndexes() map[string]int {
+	indexes := make(map[string]int)
+	for i, name := range currentLinkNames() {
+		indexes[name] = i
+	}
+	return indexes
+}
@@ -0,0 +1,376 @@
+// +build linux
+
+package psss
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+var (
+	DefaultProcScanner = NewProcScanner(runtime.NumCPU())
+
+	// globalProcFdsMutex serializes the writes of scanner workers into GlobalProcFds.
+	globalProcFdsMutex sync.Mutex
+)
+
+// ProcScanner reads /proc with a pool of workers.
+// Every worker owns its buffers, which are reused from one scan to the next.
+type ProcScanner struct {
+	mutex   sync.Mutex
+	workers []*procWorker
+	pids    []int
+	dirents []byte
+}
+
+func NewProcScanner(workers int) *ProcScanner {
+	if workers < 1 {
+		workers = 1
+	}
+	s := new(ProcScanner)
+	s.workers = make([]*procWorker, workers)
+	for i := range s.workers {
+		s.workers[i] = newProcWorker()
+	}
+	s.dirents = make([]byte, 8*OSPageSize)
+	return s
+}
+
+// Scan reads the cmdline, exe, owner and stat of every process, plus what the ProcRead* options ask for.
+// Processes are returned in /proc directory order; those exiting during the scan are left out.
+func (s *ProcScanner) Scan(options uint32) ([]*ProcInfo, error) {
+	return s.scan(func(w *procWorker, pid int) *ProcInfo {
+		proc := NewProcInfo()
+		proc.Stat.Pid = pid
+		if err := w.readStatic(proc); err != nil {
+			return nil
+		}
+		if err := w.readStat(proc); err != nil {
+			return nil
+		}
+		w.readOptions(proc, options)
+		return proc
+	})
+}
+
+func (s *ProcScanner) scan(read func(w *procWorker, pid int) *ProcInfo) ([]*ProcInfo, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	procfd, err := unix.Open(ProcRoot, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer unix.Close(procfd)
+	if err = s.readPids(procfd); err != nil {
+		return nil, err
+	}
+
+	procs := make([]*ProcInfo, len(s.pids))
+	var (
+		wg     sync.WaitGroup
+		cursor int64 = -1
+	)
+	for _, w := range s.workers {
+		wg.Add(1)
+		go func(w *procWorker) {
+			defer wg.Done()
+			w.procfd = procfd
+			for i := int(atomic.AddInt64(&cursor, 1)); i < len(s.pids); i = int(atomic.AddInt64(&cursor, 1)) {
+				procs[i] = read(w, s.pids[i])
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	n := 0
+	for _, proc := range procs {
+		if proc != nil {
+			procs[n] = proc
+			n++
+		}
+	}
+	return procs[:n], nil
+}
+
+func (s *ProcScanner) readPids(procfd int) error {
+	s.pids = s.pids[:0]
+	for {
+		n, err := unix.Getdents(procfd, s.dirents)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+		forEachDirent(s.dirents[:n], func(name []byte) {
+			if pid, ok := parseDecimal(name); ok && name[0] != '-' {
+				s.pids = append(s.pids, int(pid))
+			}
+		})
+	}
+}
+
+// forEachDirent calls fn with the name of every linux_dirent64 record in buf, skipping "." and "..".
+// The name is only valid during the call.
+func forEachDirent(buf []byte, fn func(name []byte)) {
+	var (
+		cursor int
+		reclen int
+		end    int
+	)
+	for cursor+19 < len(buf) {
+		reclen = int(*(*uint16)(unsafe.Pointer(&buf[cursor+16])))
+		if reclen == 0 {
+			return
+		}
+		for end = cursor + 19; end < cursor+reclen && buf[end] != byte(0); end++ {
+		}
+		name := buf[cursor+19 : end]
+		cursor += reclen
+		if len(name) == 0 || (name[0] == '.' && (len(name) == 1 || (len(name) == 2 && name[1] == '.'))) {
+			continue
+		}
+		fn(name)
+	}
+}
+
+type procWorker struct {
+	procfd     int
+	pathBuffer []byte
+	fileBuffer []byte
+	fdDirents  []byte
+	fdStat     syscall.Stat_t
+}
+
+func newProcWorker() *procWorker {
+	w := new(procWorker)
+	w.pathBuffer = make([]byte, 0, 64)
+	w.fileBuffer = make([]byte, OSPageSize)
+	w.fdDirents = make([]byte, OSPageSize)
+	return w
+}
+
+// path formats "<pid>/<name>\0" relative to /proc into the path buffer.
+func (w *procWorker) path(pid int, name string) []byte {
+	w.pathBuffer = strconv.AppendInt(w.pathBuffer[:0], int64(pid), 10)
+	w.pathBuffer = append(w.pathBuffer, '/')
+	w.pathBuffer = append(w.pathBuffer, name...)
+	w.pathBuffer = append(w.pathBuffer, byte(0))
+	return w.pathBuffer
+}
+
+// openat opens a NUL terminated path relative to /proc without converting it to a string.
+func (w *procWorker) openat(path []byte, flags int) (int, error) {
+	fd, _, errno := unix.Syscall6(unix.SYS_OPENAT, uintptr(w.procfd), uintptr(unsafe.Pointer(&path[0])),
+		uintptr(flags|unix.O_RDONLY|unix.O_CLOEXEC), 0, 0, 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+// readFile reads the whole file into the file buffer, growing it when needed.
+// The returned slice is only valid until the next read.
+func (w *procWorker) readFile(pid int, name string) ([]byte, error) {
+	fd, err := w.openat(w.path(pid, name), 0)
+	if err != nil {
+		return nil, err
+	}
+	defer unix.Close(fd)
+	var n, c int
+	for {
+		if n == len(w.fileBuffer) {
+			w.fileBuffer = append(w.fileBuffer, make([]byte, len(w.fileBuffer))...)
+		}
+		if c, err = unix.Read(fd, w.fileBuffer[n:]); err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+			return nil, err
+		}
+		if c == 0 {
+			return w.fileBuffer[:n], nil
+		}
+		n += c
+	}
+}
+
+// readStatic reads what does not change during the life of a process.
+// Only a missing cmdline is an error, exe is unreadable for other users' processes.
+func (w *procWorker) readStatic(p *ProcInfo) error {
+	if err := w.readCmdline(p); err != nil {
+		return err
+	}
+	if err := w.readOwner(p); err != nil {
+		return err
+	}
+	p.Exe = w.readlink(p.Stat.Pid, "exe")
+	return nil
+}
+
+// readOwner reads the effective uid of the process, owner of its /proc directory, which setuid changes without execve.
+func (w *procWorker) readOwner(p *ProcInfo) error {
+	var st unix.Stat_t
+	if err := unix.Fstatat(w.procfd, strconv.Itoa(p.Stat.Pid), &st, 0); err != nil {
+		return err
+	}
+	p.UID = st.Uid
+	return nil
+}
+
+func (w *procWorker) readCmdline(p *ProcInfo) error {
+	raw, err := w.readFile(p.Stat.Pid, "cmdline")
+	if err != nil {
+		return err
+	}
+	p.Cmdline = strings.Split(strings.Replace(string(raw), "\n", "", -1), string(byte(0)))
+	return nil
+}
+
+func (w *procWorker) readStat(p *ProcInfo) error {
+	raw, err := w.readFile(p.Stat.Pid, "stat")
+	if err != nil {
+		return err
+	}
+	return ParseProcStat(raw, &p.Stat)
+}
+
+// readOptions reads the optional files, a process denying access to them is still reported.
+func (w *procWorker) readOptions(p *ProcInfo, options uint32) {
+	var (
+		raw []byte
+		err error
+	)
+	if options&ProcReadFds != 0 {
+		w.readFds(p)
+	}
+	if options&ProcReadStatus != 0 {
+		if raw, err = w.readFile(p.Stat.Pid, "status"); err == nil {
+			p.Status = new(ProcStatus)
+			if err = p.Status.Parse(raw); err != nil {
+				p.Status = nil
+			}
+		}
+	}
+	if options&ProcReadIO != 0 {
+		if raw, err = w.readFile(p.Stat.Pid, "io"); err == nil {
+			p.IO = new(ProcIO)
+			if err = p.IO.Parse(raw); err != nil {
+				p.IO = nil
+			}
+		}
+	}
+	if options&ProcReadLimits != 0 {
+		if raw, err = w.readFile(p.Stat.Pid, "limits"); err == nil {
+			p.Limits = new(ProcLimits)
+			if err = p.Limits.Parse(raw); err != nil {
+				p.Limits = nil
+			}
+		}
+	}
+	if options&ProcReadLinks != 0 {
+		p.Cwd = w.readlink(p.Stat.Pid, "cwd")
+		p.Root = w.readlink(p.Stat.Pid, "root")
+	}
+	if options&ProcReadEnviron != 0 {
+		if raw, err = w.readFile(p.Stat.Pid, "environ"); err == nil {
+			p.Environ = ParseEnviron(raw)
+		}
+	}
+	if options&ProcReadMemory != 0 {
+		raw, err = w.readFile(p.Stat.Pid, "smaps_rollup")
+		if os.IsNotExist(err) {
+			raw, err = w.readFile(p.Stat.Pid, "smaps")
+		}
+		if err == nil {
+			p.Memory = new(ProcMemory)
+			if err = p.Memory.Parse(raw); err != nil {
+				p.Memory = nil
+			}
+		}
+	}
+	if options&ProcReadCgroup != 0 {
+		if raw, err = w.readFile(p.Stat.Pid, "cgroup"); err == nil {
+			p.Cgroup = NewProcCgroup()
+			if err = p.Cgroup.Parse(raw); err != nil {
+				p.Cgroup = nil
+			}
+		}
+	}
+	if options&ProcReadThreads != 0 {
+		w.readThreads(p)
+	}
+	if options&ProcReadOpenFiles != 0 {
+		w.readOpenFiles(p)
+	}
+	if options&ProcReadMaps != 0 {
+		if raw, err = w.readFile(p.Stat.Pid, "maps"); err == nil {
+			p.MappedFiles = ParseMappedFiles(raw)
+		}
+	}
+	if options&ProcReadSchedstat != 0 {
+		w.readSchedstat(p)
+	}
+}
+
+func (w *procWorker) readlink(pid int, name string) string {
+	n, err := unix.Readlinkat(w.procfd, strconv.Itoa(pid)+"/"+name, w.fileBuffer)
+	if err != nil {
+		return ""
+	}
+	return string(w.fileBuffer[:n])
+}
+
+// readFds is the worker counterpart of ProcInfo.GetFds.
+func (w *procWorker) readFds(p *ProcInfo) error {
+	fd, err := w.openat(w.path(p.Stat.Pid, "fd"), unix.O_DIRECTORY)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	fdPath := ProcRoot + "/" + strconv.Itoa(p.Stat.Pid) + "/fd/"
+	fds := make(map[uint32]Fd)
+	var n, count int
+	for {
+		if n, err = unix.Getdents(fd, w.fdDirents); err != nil {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+		forEachDirent(w.fdDirents[:n], func(name []byte) {
+			if err := syscall.Stat(fdPath+string(name), &w.fdStat); err != nil {
+				return
+			}
+			fds[uint32(w.fdStat.Ino)] = Fd{Dirent: Dirent{Inode: w.fdStat.Ino, Type: direntType(w.fdStat.Mode), Name: string(name)}, Fresh: true}
+			count++
+		})
+	}
+	p.Fds = fds
+	p.NumFds = count
+	if len(fds) == 0 {
+		return nil
+	}
+
+	globalProcFdsMutex.Lock()
+	defer globalProcFdsMutex.Unlock()
+	if _, ok := GlobalProcFds[p.Stat.Name]; !ok {
+		GlobalProcFds[p.Stat.Name] = make(map[int]map[uint32]Fd)
+	}
+	if _, ok := GlobalProcFds[p.Stat.Name][p.Stat.Pid]; !ok {
+		GlobalProcFds[p.Stat.Name][p.Stat.Pid] = make(map[uint32]Fd, len(fds))
+	}
+	for inode, f := range fds {
+		GlobalProcFds[p.Stat.Name][p.Stat.Pid][inode] = f
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+// +build linux
+
+package psss
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// sscanfProcStat is the fmt.Sscanf parser ParseProcStat replaced, kept as the reference of its output.
+// It stops at the first space, so it cannot parse names containing spaces.
+func sscanfProcStat(raw []byte, st *ProcStat) error {
+	n, err := fmt.Sscanf(string(bytes.TrimSuffix(raw, []byte("\n"))),
+		`%d %s %c %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d`,
+		&st.Pid, &st.Name, &st.State,
+		&st.Ppid, &st.Pgrp, &st.Session, &st.TtyNr, &st.Tpgid,
+		&st.Flags, &st.Minflt, &st.Cminflt, &st.Majflt, &st.Cmajflt,
+		&st.Utime, &st.Stime, &st.Cutime, &st.Cstime,
+		&st.Priority, &st.Nice,
+		&st.NumThreads, &st.Itrealvalue, &st.Starttime,
+		&st.Vsize, &st.Rss, &st.Rsslim,
+		&st.Startcode, &st.Endcode, &st.Startstack,
+		&st.Kstkesp, &st.Kstkeip,
+		&st.Signal, &st.Blocked, &st.Sigignore, &st.Sigcatch,
+		&st.Wchan,
+		&st.Nswap, &st.Cnswap,
+		&st.ExitSignal,
+		&st.Processor,
+		&st.RtPriority, &st.Policy,
+		&st.DelayacctBlkioTicks,
+		&st.GuestTime, &st.CguestTime,
+		&st.StartData, &st.EndData, &st.StartBrk,
+		&st.ArgStart, &st.ArgEnd, &st.EnvStart, &st.EnvEnd, &st.ExitCode,
+	)
+	if err != nil {
+		return err
+	}
+	if n < 52 {
+		return fmt.Errorf("not enough param read")
+	}
+	st.Name = strings.TrimSuffix(strings.TrimPrefix(st.Name, "("), ")")
+	return nil
+}
+
+// sequentialScanProcFS is the scan ProcScanner replaced: one process after the other,
+// reading cmdline and stat through os.Open and fmt.Sscanf.
+func sequentialScanProcFS() ([]*ProcInfo, error) {
+	fd, err := os.Open(ProcRoot)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	procs := make([]*ProcInfo, 0)
+	go procDirentReader.Scan(fd)
+	for procDirentReader.ExternalDirent = range procDirentReader.DataChan {
+		if procDirentReader.ExternalDirent.IsEnd {
+			break
+		}
+		proc := NewProcInfo()
+		if proc.Stat.Pid, err = strconv.Atoi(procDirentReader.ExternalDirent.Name); err != nil {
+			continue
+		}
+		if err = proc.GetCmdline(); err != nil {
+			continue
+		}
+		raw, err := ioutil.ReadFile(ProcRoot + fmt.Sprintf("/%d/stat", proc.Stat.Pid))
+		if err != nil {
+			continue
+		}
+		if err = sscanfProcStat(raw, &proc.Stat); err != nil {
+			continue
+		}
+		procs = append(procs, proc)
+	}
+	return procs, nil
+}
+
+// diffProcStat names the fields of got that differ from want.
+func diffProcStat(got, want *ProcStat) []string {
+	diffs := make([]string, 0)
+	gv, wv := reflect.ValueOf(got).Elem(), reflect.ValueOf(want).Elem()
+	for i := 0; i < gv.NumField(); i++ {
+		if !reflect.DeepEqual(gv.Field(i).Interface(), wv.Field(i).Interface()) {
+			diffs = append(diffs, fmt.Sprintf("%s: got %v, want %v", gv.Type().Field(i).Name, gv.Field(i).Interface(), wv.Field(i).Interface()))
+		}
+	}
+	return diffs
+}
+
+func readSelfStat(t testing.TB) []byte {
+	raw, err := ioutil.ReadFile(ProcRoot + "/self/stat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestParseProcStatMatchesSscanf(t *testing.T) {
+	raw := readSelfStat(t)
+	var got, want ProcStat
+	if err := ParseProcStat(raw, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := sscanfProcStat(raw, &want); err != nil {
+		t.Fatal(err)
+	}
+	for _, diff := range diffProcStat(&got, &want) {
+		t.Error(diff)
+	}
+}
+
+func TestParseProcStatNameWithSpacesAndParen(t *testing.T) {
+	raw := readSelfStat(t)
+	lparen, rparen := bytes.IndexByte(raw, '('), bytes.LastIndexByte(raw, ')')
+	replace := func(name string) []byte {
+		line := append([]byte(nil), raw[:lparen+1]...)
+		line = append(line, name...)
+		return append(line, raw[rparen:]...)
+	}
+
+	// the old parser reads the same line with a name it can handle, which gives the expected numeric fields
+	var want ProcStat
+	if err := sscanfProcStat(replace("name"), &want); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a b", "a) b", "(x) ) (", ") 1 2 3"} {
+		var got ProcStat
+		if err := ParseProcStat(replace(name), &got); err != nil {
+			t.Fatalf("name:[%s] %v", name, err)
+		}
+		if got.Name != name {
+			t.Errorf("got name:[%s], want:[%s]", got.Name, name)
+		}
+		got.Name = want.Name
+		for _, diff := range diffProcStat(&got, &want) {
+			t.Errorf("name:[%s] %s", name, diff)
+		}
+	}
+}
+
+func TestScanMatchesSequential(t *testing.T) {
+	want, err := sequentialScanProcFS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := NewProcScanner(4).Scan(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byPid := make(map[int]*ProcInfo, len(got))
+	for _, p := range got {
+		byPid[p.Stat.Pid] = p
+	}
+	for _, w := range want {
+		g, ok := byPid[w.Stat.Pid]
+		// processes may come and go between both scans, only the pids seen by both are compared
+		if !ok || g.Stat.Starttime != w.Stat.Starttime {
+			continue
+		}
+		if !reflect.DeepEqual(g.Cmdline, w.Cmdline) {
+			t.Errorf("pid:[%d] got cmdline:%q, want:%q", w.Stat.Pid, g.Cmdline, w.Cmdline)
+		}
+		if g.Stat.Name != w.Stat.Name || g.Stat.Ppid != w.Stat.Ppid || g.Stat.Pgrp != w.Stat.Pgrp || g.Stat.Session != w.Stat.Session {
+			t.Errorf("pid:[%d] got stat:%+v, want:%+v", w.Stat.Pid, g.Stat, w.Stat)
+		}
+	}
+}
+
+func BenchmarkParseProcStat(b *testing.B) {
+	raw := readSelfStat(b)
+	b.Run("Sscanf", func(b *testing.B) {
+		b.ReportAllocs()
+		var st ProcStat
+		for i := 0; i < b.N; i++ {
+			if err := sscanfProcStat(raw, &st); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("ParseProcStat", func(b *testing.B) {
+		b.ReportAllocs()
+		var st ProcStat
+		for i := 0; i < b.N; i++ {
+			if err := ParseProcStat(raw, &st); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkScan(b *testing.B) {
+	b.Run("Sequential", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := sequentialScanProcFS(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("ProcScanner", func(b *testing.B) {
+		b.ReportAllocs()
+		s := NewProcScanner(runtime.NumCPU())
+		for i := 0; i < b.N; i++ {
+			if _, err := s.Scan(0); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
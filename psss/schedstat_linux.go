@@ -0,0 +1,208 @@
+// +build linux
+
+package psss
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ProcSchedstat is /proc/[pid]/task/[tid]/schedstat, available with CONFIG_SCHED_INFO.
+// /proc/[pid]/schedstat only covers the thread group leader, so the schedstat of a process
+// is the sum of those of its threads, kept in Tasks.
+// definition comes from https://docs.kernel.org/scheduler/sched-stats.html
+type ProcSchedstat struct {
+	RunTime    uint64                 // nanoseconds spent on the cpu
+	RunDelay   uint64                 // nanoseconds spent waiting on a runqueue
+	Timeslices uint64                 // timeslices run on this cpu
+	Tasks      map[int]*ProcSchedstat // per thread, keyed by tid; nil for a thread or a sum of processes
+}
+
+func (ps *ProcSchedstat) Parse(raw []byte) error {
+	fields := strings.Fields(string(raw))
+	if len(fields) < 3 {
+		return fmt.Errorf("not enough param read")
+	}
+	var err error
+	for i, v := range []*uint64{&ps.RunTime, &ps.RunDelay, &ps.Timeslices} {
+		if *v, err = strconv.ParseUint(fields[i], 10, 64); err != nil {
+			return fmt.Errorf("parse field:[%s] error:[%v]", fields[i], err)
+		}
+	}
+	return nil
+}
+
+func (ps *ProcSchedstat) Add(other *ProcSchedstat) {
+	ps.RunTime += other.RunTime
+	ps.RunDelay += other.RunDelay
+	ps.Timeslices += other.Timeslices
+}
+
+// Sub subtracts the schedstat of the same process read earlier. With Tasks on both sides the threads are
+// subtracted one by one: threads exited since other are left out instead of making the sum go backward,
+// and threads started since other keep their whole counters, all spent in between.
+func (ps *ProcSchedstat) Sub(other *ProcSchedstat) {
+	if ps.Tasks == nil || other.Tasks == nil {
+		ps.RunTime -= other.RunTime
+		ps.RunDelay -= other.RunDelay
+		ps.Timeslices -= other.Timeslices
+		return
+	}
+	ps.RunTime, ps.RunDelay, ps.Timeslices = 0, 0, 0
+	for tid, task := range ps.Tasks {
+		if prev, ok := other.Tasks[tid]; ok {
+			task.Sub(prev)
+		}
+		ps.Add(task)
+	}
+}
+
+// GetSchedstat sums /proc/[pid]/task/[tid]/schedstat over the threads of the process.
+func (p *ProcInfo) GetSchedstat() error {
+	taskPath := ProcRoot + "/" + strconv.Itoa(p.Stat.Pid) + "/task/"
+	fd, err := os.Open(taskPath)
+	if err != nil {
+		return err
+	}
+	names, err := fd.Readdirnames(-1)
+	fd.Close()
+	if err != nil {
+		return err
+	}
+	ps := &ProcSchedstat{Tasks: make(map[int]*ProcSchedstat, len(names))}
+	for _, name := range names {
+		tid, err := strconv.Atoi(name)
+		if err != nil {
+			continue
+		}
+		raw, err := ioutil.ReadFile(taskPath + name + "/schedstat")
+		if err != nil {
+			// exited since the listing
+			continue
+		}
+		if err = ps.addTask(tid, raw); err != nil {
+			return err
+		}
+	}
+	p.Schedstat = ps
+	return nil
+}
+
+// readSchedstat is the worker counterpart of ProcInfo.GetSchedstat, Schedstat is left nil when unreadable.
+func (w *procWorker) readSchedstat(p *ProcInfo) {
+	tids, err := w.readTids(p)
+	if err != nil {
+		return
+	}
+	ps := &ProcSchedstat{Tasks: make(map[int]*ProcSchedstat, len(tids))}
+	for _, tid := range tids {
+		raw, err := w.readFile(p.Stat.Pid, "task/"+strconv.Itoa(tid)+"/schedstat")
+		if err != nil {
+			continue
+		}
+		if err = ps.addTask(tid, raw); err != nil {
+			return
+		}
+	}
+	p.Schedstat = ps
+}
+
+func (ps *ProcSchedstat) addTask(tid int, raw []byte) error {
+	task := new(ProcSchedstat)
+	if err := task.Parse(raw); err != nil {
+		return err
+	}
+	ps.Tasks[tid] = task
+	ps.Add(task)
+	return nil
+}
+
+// ServiceSchedstat sums the schedstat of all the processes of every service, as grouped by GetProcInfo.
+// Processes without Schedstat, not scanned with ProcReadSchedstat, are skipped.
+func ServiceSchedstat(pi map[string]map[int]*ProcInfo) map[string]*ProcSchedstat {
+	ss := make(map[string]*ProcSchedstat)
+	for name, procs := range pi {
+		for _, proc := range procs {
+			if proc.Schedstat == nil {
+				continue
+			}
+			if _, ok := ss[name]; !ok {
+				ss[name] = new(ProcSchedstat)
+			}
+			ss[name].Add(proc.Schedstat)
+		}
+	}
+	return ss
+}
+
+// CPUSchedstat is a cpu line of /proc/schedstat, version 15.
+type CPUSchedstat struct {
+	CPU         int
+	YldCount    uint64 // sched_yield() calls
+	SchedCount  uint64 // schedule() calls
+	SchedGoidle uint64 // schedule() calls leaving the cpu idle
+	TtwuCount   uint64 // try_to_wake_up() calls
+	TtwuLocal   uint64 // try_to_wake_up() calls waking up a task on this cpu
+	RunTime     uint64 // nanoseconds spent running by the tasks of this cpu
+	RunDelay    uint64 // nanoseconds spent waiting to run by the tasks of this cpu
+	Timeslices  uint64
+}
+
+// SystemSchedstat is /proc/schedstat, available with CONFIG_SCHEDSTATS.
+// The domain lines are not read.
+type SystemSchedstat struct {
+	Version   int
+	Timestamp uint64 // jiffies
+	CPUs      []*CPUSchedstat
+}
+
+func (ss *SystemSchedstat) Get() error {
+	raw, err := ioutil.ReadFile(ProcRoot + "/schedstat")
+	if err != nil {
+		return err
+	}
+	return ss.Parse(raw)
+}
+
+func (ss *SystemSchedstat) Parse(raw []byte) (err error) {
+	ss.CPUs = ss.CPUs[:0]
+	for _, line := range strings.Split(string(raw), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch {
+		case fields[0] == "version":
+			if ss.Version, err = strconv.Atoi(fields[1]); err != nil {
+				return fmt.Errorf("parse version:[%s] error:[%v]", fields[1], err)
+			}
+		case fields[0] == "timestamp":
+			if ss.Timestamp, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+				return fmt.Errorf("parse timestamp:[%s] error:[%v]", fields[1], err)
+			}
+		case strings.HasPrefix(fields[0], "cpu"):
+			if len(fields) < 10 {
+				return fmt.Errorf("line:[%s] too short", line)
+			}
+			cs := new(CPUSchedstat)
+			if cs.CPU, err = strconv.Atoi(strings.TrimPrefix(fields[0], "cpu")); err != nil {
+				return fmt.Errorf("parse cpu:[%s] error:[%v]", fields[0], err)
+			}
+			// the second field is an always zero legacy counter
+			for i, v := range []*uint64{&cs.YldCount, nil, &cs.SchedCount, &cs.SchedGoidle, &cs.TtwuCount,
+				&cs.TtwuLocal, &cs.RunTime, &cs.RunDelay, &cs.Timeslices} {
+				if v == nil {
+					continue
+				}
+				if *v, err = strconv.ParseUint(fields[i+1], 10, 64); err != nil {
+					return fmt.Errorf("parse field:[%s] error:[%v]", fields[i+1], err)
+				}
+			}
+			ss.CPUs = append(ss.CPUs, cs)
+		}
+	}
+	return nil
+}
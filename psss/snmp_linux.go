@@ -0,0 +1,200 @@
+// +build linux
+
+package psss
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// snmp6Protocols are the prefixes of the counter names of /proc/net/snmp6, longest first.
+var snmp6Protocols = []string{"UdpLite6", "Icmp6", "Udp6", "Ip6"}
+
+// SnmpCounters holds the kernel network counters keyed by protocol then name,
+// such as Tcp RetransSegs, TcpExt ListenOverflows, Udp RcvbufErrors or Ip6 InReceives.
+// The gauges among them, such as Tcp CurrEstab, are told apart by SnmpIsGauge.
+type SnmpCounters map[string]map[string]int64
+
+func NewSnmpCounters() SnmpCounters {
+	return make(SnmpCounters)
+}
+
+// Get reads /proc/net/snmp, /proc/net/snmp6 and /proc/net/netstat of the network namespace of the caller.
+// snmp6 is missing when IPv6 is disabled, and skipped then.
+func (sc SnmpCounters) Get() error {
+	if err := sc.ReadSnmp(); err != nil {
+		return err
+	}
+	if err := sc.ReadNetstat(); err != nil {
+		return err
+	}
+	if err := sc.ReadSnmp6(); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (sc SnmpCounters) ReadSnmp() error {
+	raw, err := ioutil.ReadFile(ProcRoot + "/net/snmp")
+	if err != nil {
+		return err
+	}
+	return sc.ParseTable(raw)
+}
+
+func (sc SnmpCounters) ReadNetstat() error {
+	raw, err := ioutil.ReadFile(ProcRoot + "/net/netstat")
+	if err != nil {
+		return err
+	}
+	return sc.ParseTable(raw)
+}
+
+func (sc SnmpCounters) ReadSnmp6() error {
+	raw, err := ioutil.ReadFile(ProcRoot + "/net/snmp6")
+	if err != nil {
+		return err
+	}
+	return sc.ParseSnmp6(raw)
+}
+
+// ParseTable parses the format of /proc/net/snmp and /proc/net/netstat: for every protocol,
+// a line naming the counters followed by a line of their values, both starting with the protocol.
+func (sc SnmpCounters) ParseTable(raw []byte) error {
+	lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+	if len(lines)%2 != 0 {
+		return fmt.Errorf("odd number of lines:[%d]", len(lines))
+	}
+	for i := 0; i < len(lines); i += 2 {
+		names := strings.Fields(lines[i])
+		values := strings.Fields(lines[i+1])
+		if len(names) == 0 || len(values) == 0 || names[0] != values[0] {
+			return fmt.Errorf("header:[%s] does not match values:[%s]", lines[i], lines[i+1])
+		}
+		if len(names) != len(values) {
+			return fmt.Errorf("%s has %d names for %d values", names[0], len(names)-1, len(values)-1)
+		}
+		proto := strings.TrimSuffix(names[0], ":")
+		counters, ok := sc[proto]
+		if !ok {
+			counters = make(map[string]int64, len(names)-1)
+			sc[proto] = counters
+		}
+		for j := 1; j < len(names); j++ {
+			v, err := strconv.ParseInt(values[j], 10, 64)
+			if err != nil {
+				return fmt.Errorf("parse %s %s error:[%v]", proto, names[j], err)
+			}
+			counters[names[j]] = v
+		}
+	}
+	return nil
+}
+
+// ParseSnmp6 parses the "name value" lines of /proc/net/snmp6, splitting the protocol off the name,
+// Ip6InReceives being Ip6 InReceives.
+func (sc SnmpCounters) ParseSnmp6(raw []byte) error {
+	for _, line := range strings.Split(strings.TrimSpace(string(raw)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return fmt.Errorf("invalid line:[%s]", line)
+		}
+		v, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("parse field:[%s] error:[%v]", fields[0], err)
+		}
+		proto, name := "Ip6", fields[0]
+		for _, p := range snmp6Protocols {
+			if strings.HasPrefix(fields[0], p) {
+				proto, name = p, strings.TrimPrefix(fields[0], p)
+				break
+			}
+		}
+		if _, ok := sc[proto]; !ok {
+			sc[proto] = make(map[string]int64)
+		}
+		sc[proto][name] = v
+	}
+	return nil
+}
+
+// Value returns a counter, 0 when the kernel does not have it.
+func (sc SnmpCounters) Value(proto, name string) int64 {
+	return sc[proto][name]
+}
+
+// SnmpIsGauge tells whether the counter is a current value or a setting rather than a count since boot.
+func SnmpIsGauge(proto, name string) bool {
+	switch proto {
+	case "Ip":
+		return name == "Forwarding" || name == "DefaultTTL"
+	case "Tcp":
+		return name == "RtoAlgorithm" || name == "RtoMin" || name == "RtoMax" || name == "MaxConn" || name == "CurrEstab"
+	}
+	return false
+}
+
+// Sub turns the counters into their increase since prev, gauges keep their current value.
+// Counters absent from prev have no delta and are dropped.
+func (sc SnmpCounters) Sub(prev SnmpCounters) {
+	for proto, counters := range sc {
+		for name, v := range counters {
+			if SnmpIsGauge(proto, name) {
+				continue
+			}
+			prevv, ok := prev[proto][name]
+			if !ok {
+				delete(counters, name)
+				continue
+			}
+			counters[name] = v - prevv
+		}
+	}
+}
+
+// Add sums every counter of other, gauges included, so that Div averages them.
+func (sc SnmpCounters) Add(other SnmpCounters) {
+	for proto, counters := range sc {
+		for name := range counters {
+			counters[name] += other[proto][name]
+		}
+	}
+}
+
+func (sc SnmpCounters) Div(n uint64) {
+	for _, counters := range sc {
+		for name := range counters {
+			counters[name] /= int64(n)
+		}
+	}
+}
+
+// SnmpCounter is a counter of SnmpCounters, as listed by Sorted.
+type SnmpCounter struct {
+	Proto string
+	Name  string
+	Value int64
+}
+
+// Sorted lists the counters by protocol and name, leaving out the zero ones unless zero is set.
+func (sc SnmpCounters) Sorted(zero bool) []SnmpCounter {
+	list := make([]SnmpCounter, 0)
+	for proto, counters := range sc {
+		for name, v := range counters {
+			if v != 0 || zero {
+				list = append(list, SnmpCounter{proto, name, v})
+			}
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Proto != list[j].Proto {
+			return list[i].Proto < list[j].Proto
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
@@ -165,7 +165,9 @@ type SocketInfo struct {
 	Probes     int // unanswered 0-window probes
 	Inode      uint32
 	RefCount   int
+	Interface  uint32 // index of the device the socket is bound to, 0 when unbound; see LinkName
 	SK         uint64
+	FromProc   bool // read from /proc/net/* as inet_diag failed, which reports no backlog for listeners
 	// /proc/net/tcp or /proc/net/tcp6 specific
 	RTO                float64  // RetransmitTimeout
 	ATO                float64  // Predicted tick of soft clock (delayed ACK control data)
@@ -183,6 +185,7 @@ type SocketInfo struct {
 	Meminfo []uint32
 	// Related processes
 	UserName string
+	Cgroup   *ProcCgroup // cgroup of the owning process, filled by SetUpCgroup
 	// Flag
 	IsEnd bool
 }
@@ -207,7 +210,9 @@ func (si *SocketInfo) Reset() {
 	si.Probes = 0
 	si.Inode = 0
 	si.RefCount = 0
+	si.Interface = 0
 	si.SK = 0
+	si.FromProc = false
 	si.RTO = 0
 	si.ATO = 0
 	si.QACK = 0
@@ -221,6 +226,7 @@ func (si *SocketInfo) Reset() {
 	si.Type = 0
 	si.Meminfo = nil
 	si.UserName = ""
+	si.Cgroup = nil
 	si.IsEnd = false
 }
 
@@ -239,6 +245,17 @@ func (si *SocketInfo) SetUpRelation() {
 	}
 }
 
+// SetUpCgroup attributes the socket to the cgroup of the first process holding it.
+// Processes without Cgroup, not scanned with ProcReadCgroup, are skipped.
+func (si *SocketInfo) SetUpCgroup(index ProcFdIndex) {
+	for _, proc := range index[si.Inode] {
+		if proc.Cgroup != nil {
+			si.Cgroup = proc.Cgroup
+			return
+		}
+	}
+}
+
 func (si *SocketInfo) GenericInfoPrint() {
 	if len(Sstate[si.Status]) >= 8 {
 		fmt.Printf("%s\t", Sstate[si.Status])
@@ -276,9 +293,23 @@ func (si *SocketInfo) ExtendInfoPrint() {
 		fmt.Printf("uid:%d,", si.UID)
 	}
 	fmt.Printf("ino:%d,sk:%x", si.Inode, si.SK)
+	if si.Interface != 0 {
+		fmt.Printf(",dev:%s", LinkName(int(si.Interface)))
+	}
 	if len(si.Opt) > 0 {
 		fmt.Printf(",opt:%v", si.Opt)
 	}
+	if si.Cgroup != nil {
+		if len(si.Cgroup.ContainerID) > 0 {
+			fmt.Printf(",container:%s:%.12s", si.Cgroup.ContainerRuntime, si.Cgroup.ContainerID)
+		}
+		if len(si.Cgroup.PodUID) > 0 {
+			fmt.Printf(",pod:%s", si.Cgroup.PodUID)
+		}
+		if len(si.Cgroup.Unit) > 0 {
+			fmt.Printf(",unit:%s", si.Cgroup.Unit)
+		}
+	}
 	fmt.Printf(")]    ")
 }
 
@@ -276,9 +276,13 @@ func RecvInetDiagMsgMulti(skfd int) (err error) {
 		si.Timer = int(inDiagMsg.IdiagTimer)
 		si.Timeout = int(inDiagMsg.IdiagExpires)
 		si.Retransmit = int(inDiagMsg.IdiagRetrans)
+		// under the persist timer idiag_retrans counts the zero window probes sent
+		if si.Timer == 4 {
+			si.Probes = si.Retransmit
+		}
 		si.UID = uint64(inDiagMsg.IdiagUid)
 		si.Inode = inDiagMsg.IdiagInode
-		si.RefCount = int(inDiagMsg.ID.IdiagIF)
+		si.Interface = inDiagMsg.ID.IdiagIF
 		si.SK = uint64(inDiagMsg.ID.IdiagCookie[1])<<32 | uint64(inDiagMsg.ID.IdiagCookie[0])
 		cursor = SizeOfInetDiagMsg
 		for cursor+4 < len(raw[i].Data) {
@@ -290,9 +294,13 @@ func RecvInetDiagMsgMulti(skfd int) (err error) {
 			case INET_DIAG_MEMINFO:
 				// meminfo := *(*InetDiagMeminfo)(unsafe.Pointer(&raw[i].Data[cursor+unix.SizeofNlAttr : cursor+int(nlAttr.Len)][0]))
 			case INET_DIAG_INFO:
-				si.TCPInfo = (*TCPInfo)(unsafe.Pointer(&raw[i].Data[cursor+unix.SizeofNlAttr : cursor+int(nlAttr.Len)][0]))
+				// copied out of sockDiagMsgBuffer, which the next batch overwrites,
+				// and zero padded when the kernel has a shorter tcp_info
+				si.TCPInfo = new(TCPInfo)
+				copy((*[unsafe.Sizeof(TCPInfo{})]byte)(unsafe.Pointer(si.TCPInfo))[:], raw[i].Data[cursor+unix.SizeofNlAttr:cursor+int(nlAttr.Len)])
 			case INET_DIAG_VEGASINFO:
-				si.VegasInfo = (*TCPVegasInfo)(unsafe.Pointer(&raw[i].Data[cursor+unix.SizeofNlAttr : cursor+int(nlAttr.Len)][0]))
+				si.VegasInfo = new(TCPVegasInfo)
+				copy((*[unsafe.Sizeof(TCPVegasInfo{})]byte)(unsafe.Pointer(si.VegasInfo))[:], raw[i].Data[cursor+unix.SizeofNlAttr:cursor+int(nlAttr.Len)])
 			case INET_DIAG_CONG:
 				si.CONG = make([]byte, 0)
 				si.CONG = append(si.CONG, raw[i].Data[cursor+unix.SizeofNlAttr:cursor+int(nlAttr.Len)]...)
@@ -336,6 +344,24 @@ func RecvInetDiagMsgAll(skfd int) {
 }
 
 func GenericInetRead(protocal, af int) (sis map[uint32]SocketInfo, err error) {
+	sis = make(map[uint32]SocketInfo)
+	err = genericInetRead(protocal, af, func(si SocketInfo) {
+		sis[si.Inode] = si
+	})
+	return sis, err
+}
+
+// GenericInetList is GenericInetRead returning every socket, including those without an inode,
+// such as TIME-WAIT ones, which all share inode 0 and would overwrite each other in the map.
+func GenericInetList(protocal, af int) (sis []SocketInfo, err error) {
+	sis = make([]SocketInfo, 0)
+	err = genericInetRead(protocal, af, func(si SocketInfo) {
+		sis = append(sis, si)
+	})
+	return sis, err
+}
+
+func genericInetRead(protocal, af int, add func(si SocketInfo)) (err error) {
 	var (
 		ipproto uint8
 		exts    uint8
@@ -354,7 +380,7 @@ func GenericInetRead(protocal, af int) (sis map[uint32]SocketInfo, err error) {
 	case ProtocalRAW:
 		ipproto = unix.IPPROTO_RAW
 	default:
-		return nil, fmt.Errorf("invalid protocal:[%d]", protocal)
+		return fmt.Errorf("invalid protocal:[%d]", protocal)
 	}
 	if FlagMemory {
 		exts |= 1 << (INET_DIAG_SKMEMINFO - 1)
@@ -364,13 +390,12 @@ func GenericInetRead(protocal, af int) (sis map[uint32]SocketInfo, err error) {
 	}
 	defer unix.Close(skfd)
 
-	sis = make(map[uint32]SocketInfo)
 	go RecvInetDiagMsgAll(skfd)
 	for si := range SocketInfoChan {
 		if si.IsEnd {
-			return sis, nil
+			return nil
 		}
-		sis[si.Inode] = si
+		add(si)
 	}
 
 readProc:
@@ -383,7 +408,6 @@ readProc:
 		stringBuff  []string
 		tempInt64   int64
 	)
-	sis = make(map[uint32]SocketInfo)
 
 	switch protocal {
 	case ProtocalTCP:
@@ -400,14 +424,14 @@ readProc:
 		procPath += "6"
 	}
 	if file, err = os.Open(procFilePath[procPath]); err != nil {
-		return nil, err
+		return err
 	}
 	defer file.Close()
 
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		if err = scanner.Err(); err != nil {
-			return sis, err
+			return err
 		}
 		line = scanner.Text()
 		fields = strings.Fields(line)
@@ -415,6 +439,7 @@ readProc:
 			continue
 		}
 		si := NewSocketInfo()
+		si.FromProc = true
 		// Local address
 		fieldsIndex = 1
 		stringBuff = strings.Split(fields[fieldsIndex], ":")
@@ -568,7 +593,7 @@ readProc:
 		if FlagProcess {
 			si.SetUpRelation()
 		}
-		sis[si.Inode] = *si
+		add(*si)
 	}
 	return
 }
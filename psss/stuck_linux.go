@@ -0,0 +1,136 @@
+// +build linux
+
+package psss
+
+import (
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StuckTask is a process, or one of its threads, found in the same state for Samples consecutive samples.
+type StuckTask struct {
+	Proc    *ProcInfo
+	Stat    *ProcStat // the task itself, a thread of Proc when Stat.Pid differs from Proc.Stat.Pid
+	Samples int
+	Wchan   string   // kernel function the task sleeps in, empty when not permitted
+	Stack   []string // kernel stack, only readable by root
+}
+
+// Zombie is a process exited for Samples consecutive samples without its parent reaping it.
+type Zombie struct {
+	Proc    *ProcInfo
+	Parent  *ProcInfo // nil when the parent exited in between
+	Samples int
+}
+
+type StuckReport struct {
+	Uninterruptible []*StuckTask // in D state, the longest first
+	Zombies         []*Zombie    // the longest first
+	Stopped         []*ProcInfo  // stopped by a signal or a tracer
+}
+
+// StuckDetector flags the tasks staying in uninterruptible sleep and the zombies staying unreaped
+// for Threshold consecutive calls of Sample, so that the short waits of healthy I/O are not reported.
+type StuckDetector struct {
+	Threshold int
+	Threads   bool // also check every thread, a process whose main thread sleeps may have others blocked
+
+	scanner *ProcScanner
+	waiting map[ProcIdentity]int // consecutive samples in D state, keyed by task
+	zombies map[ProcIdentity]int
+}
+
+func NewStuckDetector(threshold int) *StuckDetector {
+	d := new(StuckDetector)
+	d.Threshold = threshold
+	d.scanner = NewProcScanner(1)
+	d.waiting = make(map[ProcIdentity]int)
+	d.zombies = make(map[ProcIdentity]int)
+	return d
+}
+
+func (d *StuckDetector) Sample() (*StuckReport, error) {
+	var options uint32
+	if d.Threads {
+		options |= ProcReadThreads
+	}
+	procs, err := d.scanner.Scan(options)
+	if err != nil {
+		return nil, err
+	}
+	byPid := make(map[int]*ProcInfo, len(procs))
+	for _, proc := range procs {
+		byPid[proc.Stat.Pid] = proc
+	}
+
+	report := new(StuckReport)
+	waiting := make(map[ProcIdentity]int)
+	zombies := make(map[ProcIdentity]int)
+	for _, proc := range procs {
+		switch proc.Stat.State {
+		case 'Z':
+			id := proc.Identity()
+			zombies[id] = d.zombies[id] + 1
+			if zombies[id] >= d.Threshold {
+				report.Zombies = append(report.Zombies, &Zombie{Proc: proc, Parent: byPid[proc.Stat.Ppid], Samples: zombies[id]})
+			}
+			continue
+		case 'T', 't':
+			report.Stopped = append(report.Stopped, proc)
+		}
+
+		tasks := []*ProcStat{&proc.Stat}
+		if d.Threads && len(proc.Threads) > 0 {
+			tasks = tasks[:0]
+			for _, st := range proc.Threads {
+				tasks = append(tasks, st)
+			}
+		}
+		for _, st := range tasks {
+			if st.State != 'D' {
+				continue
+			}
+			id := ProcIdentity{Pid: st.Pid, Starttime: st.Starttime}
+			waiting[id] = d.waiting[id] + 1
+			if waiting[id] < d.Threshold {
+				continue
+			}
+			task := &StuckTask{Proc: proc, Stat: st, Samples: waiting[id]}
+			task.readKernelState()
+			report.Uninterruptible = append(report.Uninterruptible, task)
+		}
+	}
+	d.waiting = waiting
+	d.zombies = zombies
+
+	sort.Slice(report.Uninterruptible, func(i, j int) bool {
+		return report.Uninterruptible[i].Samples > report.Uninterruptible[j].Samples
+	})
+	sort.Slice(report.Zombies, func(i, j int) bool {
+		return report.Zombies[i].Samples > report.Zombies[j].Samples
+	})
+	return report, nil
+}
+
+// readKernelState reads the wchan and stack of the task, as far as permitted.
+func (t *StuckTask) readKernelState() {
+	path := ProcRoot + "/" + strconv.Itoa(t.Proc.Stat.Pid) + "/task/" + strconv.Itoa(t.Stat.Pid) + "/"
+	if raw, err := ioutil.ReadFile(path + "wchan"); err == nil && string(raw) != "0" {
+		t.Wchan = string(raw)
+	}
+	raw, err := ioutil.ReadFile(path + "stack")
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(raw)), "\n") {
+		// [<0>] io_schedule+0x12/0x40
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			line = line[i+1:]
+		}
+		if len(line) > 0 {
+			t.Stack = append(t.Stack, line)
+		}
+	}
+}
@@ -72,6 +72,8 @@ type MemoryInfo struct {
 	DirectMap2M       uint64
 	DirectMap4M       uint64
 	DirectMap1G       uint64
+	Extra             map[string]uint64 // the fields unknown to this version, such as Zswap on recent kernels, keyed by name
+	Warnings          []*ParseWarning
 }
 
 func (mi *MemoryInfo) Get() error {
@@ -82,6 +84,8 @@ func (mi *MemoryInfo) Get() error {
 	defer fd.Close()
 
 	var v uint64
+	mi.Extra = make(map[string]uint64)
+	mi.Warnings = nil
 
 	scanner := bufio.NewScanner(fd)
 	for scanner.Scan() {
@@ -91,10 +95,12 @@ func (mi *MemoryInfo) Get() error {
 
 		fields := strings.Split(SlimSpaceRegExp.ReplaceAllString(strings.Replace(scanner.Text(), "kB", "", -1), ""), ":")
 		if len(fields) != 2 {
-			return fmt.Errorf("line:[%v] two short", fields)
+			mi.Warnings = append(mi.Warnings, &ParseWarning{ProcRoot + "/meminfo", scanner.Text(), fmt.Errorf("too short")})
+			continue
 		}
 		if v, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
-			return fmt.Errorf("parse field:[%s] error:[%v]", fields[1], err)
+			mi.Warnings = append(mi.Warnings, &ParseWarning{ProcRoot + "/meminfo", scanner.Text(), err})
+			continue
 		}
 
 		switch fields[0] {
@@ -213,7 +219,7 @@ func (mi *MemoryInfo) Get() error {
 		case "DirectMap1G":
 			mi.DirectMap1G = v
 		default:
-			fmt.Printf("invalid field:[%s] with value:[%s]\n", fields[0], fields[1])
+			mi.Extra[fields[0]] = v
 		}
 	}
 	return nil
@@ -233,17 +239,115 @@ type CPUJiffies struct {
 	Total     uint64 // not specified in /proc/stat
 }
 
+// Add, Sub and Div apply to every field, so that jiffies can be summed, subtracted and averaged across samples.
+func (cj *CPUJiffies) Add(other *CPUJiffies) {
+	cj.User += other.User
+	cj.Nice += other.Nice
+	cj.System += other.System
+	cj.Idle += other.Idle
+	cj.Iowait += other.Iowait
+	cj.Irq += other.Irq
+	cj.Softirq += other.Softirq
+	cj.Steal += other.Steal
+	cj.Guest += other.Guest
+	cj.GuestNice += other.GuestNice
+	cj.Total += other.Total
+}
+
+func (cj *CPUJiffies) Sub(other *CPUJiffies) {
+	cj.User -= other.User
+	cj.Nice -= other.Nice
+	cj.System -= other.System
+	cj.Idle -= other.Idle
+	cj.Iowait -= other.Iowait
+	cj.Irq -= other.Irq
+	cj.Softirq -= other.Softirq
+	cj.Steal -= other.Steal
+	cj.Guest -= other.Guest
+	cj.GuestNice -= other.GuestNice
+	cj.Total -= other.Total
+}
+
+func (cj *CPUJiffies) Div(n uint64) {
+	cj.User /= n
+	cj.Nice /= n
+	cj.System /= n
+	cj.Idle /= n
+	cj.Iowait /= n
+	cj.Irq /= n
+	cj.Softirq /= n
+	cj.Steal /= n
+	cj.Guest /= n
+	cj.GuestNice /= n
+	cj.Total /= n
+}
+
+// Utilization is the percent of Total not spent idle nor waiting for I/O.
+func (cj *CPUJiffies) Utilization() float64 {
+	if cj.Total == 0 {
+		return 0
+	}
+	return float64(cj.Total-cj.Idle-cj.Iowait) / float64(cj.Total) * 100
+}
+
+// parse reads the jiffies following the cpu label of a /proc/stat line.
+// Guest and GuestNice are missing before Linux 2.6.24 and 2.6.33, and left to 0.
+func (cj *CPUJiffies) parse(fields []string) error {
+	values := []*uint64{
+		&cj.User, &cj.Nice, &cj.System, &cj.Idle, &cj.Iowait,
+		&cj.Irq, &cj.Softirq, &cj.Steal, &cj.Guest, &cj.GuestNice,
+	}
+	if len(fields) < 4 {
+		return fmt.Errorf("not enough param read")
+	}
+	var err error
+	for i, v := range fields {
+		if i >= len(values) {
+			break
+		}
+		if *values[i], err = strconv.ParseUint(v, 10, 64); err != nil {
+			return fmt.Errorf("parse field:[%s] error:[%v]", v, err)
+		}
+		cj.Total += *values[i]
+	}
+	return nil
+}
+
+// SoftirqNames are the columns of the softirq line of /proc/stat, and the rows of /proc/softirqs.
+var SoftirqNames = []string{"HI", "TIMER", "NET_TX", "NET_RX", "BLOCK", "IRQ_POLL", "TASKLET", "SCHED", "HRTIMER", "RCU"}
+
 // definition comes from Linux kernel /fs/proc/stat.c
 type SystemStat struct {
 	CPUTotal        *CPUJiffies
-	PageIn, PageOut uint64 // The number of pages the system paged in and the number that were paged out (from disk).
-	SwapIn, SwapOut uint64 // The number of swap pages that have been brought in and out.
-	Intr            uint64 // This line shows counts of interrupts serviced since boot time, for each of the possible system interrupts. The first column is the total of all interrupts serviced including unnumbered architecture specific interrupts; each subsequent column is the total for that particular numbered interrupt. Unnumbered interrupts are not shown, only summed into the total.
-	Ctxt            uint64 // The number of context switches that the system underwent.
-	Btime           uint64 // boot time, in seconds since the Epoch, 1970-01-01 00:00:00 +0000 (UTC).
-	Processes       uint64 // Number of forks since boot.
-	ProcsRunning    uint64 // Number of processes in runnable state. (Linux 2.5.45 onward.)
-	ProcsBlocked    uint64 // Number of processes blocked waiting for I/O to complete. (Linux 2.5.45 onward.)
+	CPUs            map[int]*CPUJiffies // keyed by CPU number, offline CPUs are missing
+	PageIn, PageOut uint64              // The number of pages the system paged in and the number that were paged out (from disk).
+	SwapIn, SwapOut uint64              // The number of swap pages that have been brought in and out.
+	Intr            uint64              // This line shows counts of interrupts serviced since boot time, for each of the possible system interrupts. The first column is the total of all interrupts serviced including unnumbered architecture specific interrupts; each subsequent column is the total for that particular numbered interrupt. Unnumbered interrupts are not shown, only summed into the total.
+	IntrVector      []uint64            // the count of every numbered interrupt, indexed by interrupt number
+	Ctxt            uint64              // The number of context switches that the system underwent.
+	Btime           uint64              // boot time, in seconds since the Epoch, 1970-01-01 00:00:00 +0000 (UTC).
+	Processes       uint64              // Number of forks since boot.
+	ProcsRunning    uint64              // Number of processes in runnable state. (Linux 2.5.45 onward.)
+	ProcsBlocked    uint64              // Number of processes blocked waiting for I/O to complete. (Linux 2.5.45 onward.)
+	Softirq         uint64              // The number of softirqs serviced since boot time.
+	SoftirqVector   []uint64            // the count of every softirq, in the order of SoftirqNames
+}
+
+// parseCounters parses a /proc/stat line made of a total followed by its vector.
+func parseCounters(fields []string) (total uint64, vector []uint64, err error) {
+	if len(fields) < 1 {
+		return 0, nil, fmt.Errorf("not enough param read")
+	}
+	if total, err = strconv.ParseUint(fields[0], 10, 64); err != nil {
+		return 0, nil, fmt.Errorf("parse field:[%s] error:[%v]", fields[0], err)
+	}
+	vector = make([]uint64, len(fields)-1)
+	for i, v := range fields[1:] {
+		if vector[i], err = strconv.ParseUint(v, 10, 64); err != nil {
+			return 0, nil, fmt.Errorf("parse field:[%s] error:[%v]", v, err)
+		}
+	}
+	return total, vector, nil
 }
 
 func (ss *SystemStat) Get() (err error) {
@@ -252,7 +356,10 @@ func (ss *SystemStat) Get() (err error) {
 		return err
 	}
 	defer fd.Close()
+	ss.CPUs = make(map[int]*CPUJiffies)
 	scanner := bufio.NewScanner(fd)
+	// the intr line holds a column per interrupt number, beyond the default buffer on large machines
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
 	for scanner.Scan() {
 		if err = scanner.Err(); err != nil {
 			return err
@@ -261,15 +368,20 @@ func (ss *SystemStat) Get() (err error) {
 		switch {
 		case strings.Contains(line, "cpu "):
 			ss.CPUTotal = new(CPUJiffies)
-			bytesCounter, err = fmt.Sscanf(line, "cpu %d %d %d %d %d %d %d %d %d %d",
-				&ss.CPUTotal.User, &ss.CPUTotal.Nice, &ss.CPUTotal.System, &ss.CPUTotal.Idle, &ss.CPUTotal.Iowait,
-				&ss.CPUTotal.Irq, &ss.CPUTotal.Softirq, &ss.CPUTotal.Steal, &ss.CPUTotal.Guest, &ss.CPUTotal.GuestNice,
-			)
-			if bytesCounter < 10 {
-				return fmt.Errorf("not enough param read")
+			if err = ss.CPUTotal.parse(strings.Fields(line)[1:]); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "cpu"):
+			fields := strings.Fields(line)
+			cpu, err := strconv.Atoi(strings.TrimPrefix(fields[0], "cpu"))
+			if err != nil {
+				return fmt.Errorf("parse field:[%s] error:[%v]", fields[0], err)
 			}
-			ss.CPUTotal.Total = ss.CPUTotal.User + ss.CPUTotal.Nice + ss.CPUTotal.System + ss.CPUTotal.Idle + ss.CPUTotal.Iowait +
-				ss.CPUTotal.Irq + ss.CPUTotal.Softirq + ss.CPUTotal.Steal + ss.CPUTotal.Guest + ss.CPUTotal.GuestNice
+			cj := new(CPUJiffies)
+			if err = cj.parse(fields[1:]); err != nil {
+				return err
+			}
+			ss.CPUs[cpu] = cj
 		case strings.Contains(line, "page"):
 			if bytesCounter, err = fmt.Sscanf(line, "page %d %d", &ss.PageIn, &ss.PageOut); err != nil {
 				return err
@@ -285,6 +397,9 @@ func (ss *SystemStat) Get() (err error) {
 				return fmt.Errorf("not enough param read")
 			}
 		case strings.Contains(line, "intr"):
+			if ss.Intr, ss.IntrVector, err = parseCounters(strings.Fields(line)[1:]); err != nil {
+				return err
+			}
 		case strings.Contains(line, "ctxt"):
 			if bytesCounter, err = fmt.Sscanf(line, "ctxt %d", &ss.Ctxt); err != nil {
 				return err
@@ -314,17 +429,412 @@ func (ss *SystemStat) Get() (err error) {
 				return fmt.Errorf("not enough param read")
 			}
 		case strings.Contains(line, "procs_blocked"):
-			if bytesCounter, err = fmt.Sscanf(line, "procs_blocked %d", &ss.ProcsRunning); err != nil {
+			if bytesCounter, err = fmt.Sscanf(line, "procs_blocked %d", &ss.ProcsBlocked); err != nil {
 				return err
 			}
 			if bytesCounter < 1 {
 				return fmt.Errorf("not enough param read")
 			}
+		case strings.Contains(line, "softirq"):
+			if ss.Softirq, ss.SoftirqVector, err = parseCounters(strings.Fields(line)[1:]); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
+// Interrupt is a line of /proc/interrupts.
+type Interrupt struct {
+	IRQ         string   // the interrupt number, or the name of an architecture specific interrupt such as NMI or LOC
+	Counts      []uint64 // per CPU, in the order of Interrupts.CPUs
+	Description string   // chip, hardware interrupt and trigger of numbered interrupts, the meaning of the named ones
+	Devices     []string // the devices sharing a numbered interrupt, such as eth0-TxRx-0
+}
+
+// Total sums the counts of all CPUs.
+func (i *Interrupt) Total() (total uint64) {
+	for _, v := range i.Counts {
+		total += v
+	}
+	return total
+}
+
+// definition comes from Linux kernel /kernel/irq/proc.c
+type Interrupts struct {
+	CPUs []int // the online CPUs, numbering the count columns
+	IRQs []*Interrupt
+}
+
+func (is *Interrupts) Get() error {
+	raw, err := ioutil.ReadFile(ProcRoot + "/interrupts")
+	if err != nil {
+		return err
+	}
+	return is.Parse(raw)
+}
+
+func (is *Interrupts) Parse(raw []byte) (err error) {
+	lines := strings.Split(string(raw), "\n")
+	if is.CPUs, err = parseCPUHeader(lines[0]); err != nil {
+		return err
+	}
+	is.IRQs = make([]*Interrupt, 0, len(lines))
+	for _, line := range lines[1:] {
+		name, counts, rest, ok := parsePerCPULine(line, len(is.CPUs))
+		if !ok {
+			continue
+		}
+		irq := &Interrupt{IRQ: name, Counts: counts, Description: strings.Join(rest, " ")}
+		if _, err := strconv.Atoi(name); err == nil {
+			irq.parseDevices(rest)
+		}
+		is.IRQs = append(is.IRQs, irq)
+	}
+	return nil
+}
+
+// parseDevices finds the devices of a numbered interrupt after its trigger, such as 24-edge or fasteoi on x86,
+// or Level and Edge on ARM GIC. Without a known trigger, the last field is taken as the device.
+func (i *Interrupt) parseDevices(rest []string) {
+	start := len(rest) - 1
+	for j, v := range rest {
+		v = strings.ToLower(v)
+		if strings.HasSuffix(v, "edge") || strings.HasSuffix(v, "level") || strings.HasSuffix(v, "fasteoi") {
+			start = j + 1
+			break
+		}
+	}
+	if start < 0 {
+		return
+	}
+	i.Description = strings.Join(rest[:start], " ")
+	for _, v := range strings.Split(strings.Join(rest[start:], " "), ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			i.Devices = append(i.Devices, v)
+		}
+	}
+}
+
+// PerCPU sums the counts of all interrupts of every CPU, keyed by CPU number.
+func (is *Interrupts) PerCPU() map[int]uint64 {
+	perCPU := make(map[int]uint64, len(is.CPUs))
+	for _, irq := range is.IRQs {
+		for i, v := range irq.Counts {
+			perCPU[is.CPUs[i]] += v
+		}
+	}
+	return perCPU
+}
+
+// Sub subtracts the counts of prev, interrupts or CPUs absent from prev keep their whole counts.
+func (is *Interrupts) Sub(prev *Interrupts) {
+	prevIRQs := make(map[string]*Interrupt, len(prev.IRQs))
+	for _, irq := range prev.IRQs {
+		prevIRQs[irq.IRQ] = irq
+	}
+	for _, irq := range is.IRQs {
+		if previrq, ok := prevIRQs[irq.IRQ]; ok {
+			subPerCPU(is.CPUs, irq.Counts, prev.CPUs, previrq.Counts)
+		}
+	}
+}
+
+// Add adds the counts of other, interrupts absent from is are left out.
+func (is *Interrupts) Add(other *Interrupts) {
+	otherIRQs := make(map[string]*Interrupt, len(other.IRQs))
+	for _, irq := range other.IRQs {
+		otherIRQs[irq.IRQ] = irq
+	}
+	for _, irq := range is.IRQs {
+		if otherirq, ok := otherIRQs[irq.IRQ]; ok {
+			addPerCPU(is.CPUs, irq.Counts, other.CPUs, otherirq.Counts)
+		}
+	}
+}
+
+func (is *Interrupts) Div(n uint64) {
+	for _, irq := range is.IRQs {
+		for i := range irq.Counts {
+			irq.Counts[i] /= n
+		}
+	}
+}
+
+// definition comes from Linux kernel /fs/proc/softirqs.c
+type Softirqs struct {
+	CPUs   []int               // the online CPUs, numbering the count columns
+	Counts map[string][]uint64 // per CPU, keyed by the names of SoftirqNames
+}
+
+func (ss *Softirqs) Get() error {
+	raw, err := ioutil.ReadFile(ProcRoot + "/softirqs")
+	if err != nil {
+		return err
+	}
+	return ss.Parse(raw)
+}
+
+func (ss *Softirqs) Parse(raw []byte) (err error) {
+	lines := strings.Split(string(raw), "\n")
+	if ss.CPUs, err = parseCPUHeader(lines[0]); err != nil {
+		return err
+	}
+	ss.Counts = make(map[string][]uint64, len(SoftirqNames))
+	for _, line := range lines[1:] {
+		if name, counts, _, ok := parsePerCPULine(line, len(ss.CPUs)); ok {
+			ss.Counts[name] = counts
+		}
+	}
+	return nil
+}
+
+// PerCPU sums the counts of the softirqs named, or of all softirqs when none is, keyed by CPU number.
+func (ss *Softirqs) PerCPU(names ...string) map[int]uint64 {
+	if len(names) == 0 {
+		names = SoftirqNames
+	}
+	perCPU := make(map[int]uint64, len(ss.CPUs))
+	for _, name := range names {
+		for i, v := range ss.Counts[name] {
+			perCPU[ss.CPUs[i]] += v
+		}
+	}
+	return perCPU
+}
+
+func (ss *Softirqs) Sub(prev *Softirqs) {
+	for name, counts := range ss.Counts {
+		if prevcounts, ok := prev.Counts[name]; ok {
+			subPerCPU(ss.CPUs, counts, prev.CPUs, prevcounts)
+		}
+	}
+}
+
+func (ss *Softirqs) Add(other *Softirqs) {
+	for name, counts := range ss.Counts {
+		if othercounts, ok := other.Counts[name]; ok {
+			addPerCPU(ss.CPUs, counts, other.CPUs, othercounts)
+		}
+	}
+}
+
+func (ss *Softirqs) Div(n uint64) {
+	for _, counts := range ss.Counts {
+		for i := range counts {
+			counts[i] /= n
+		}
+	}
+}
+
+// parseCPUHeader reads the CPU numbers of a header line such as "CPU0 CPU1 CPU3".
+func parseCPUHeader(line string) ([]int, error) {
+	fields := strings.Fields(line)
+	cpus := make([]int, 0, len(fields))
+	for _, v := range fields {
+		cpu, err := strconv.Atoi(strings.TrimPrefix(v, "CPU"))
+		if err != nil {
+			return nil, fmt.Errorf("parse cpu:[%s] error:[%v]", v, err)
+		}
+		cpus = append(cpus, cpu)
+	}
+	return cpus, nil
+}
+
+// parsePerCPULine splits a line such as "NET_RX: 12 34" or " 24: 12 34 IR-PCI-MSI 524288-edge eth0" into its name,
+// its counts and the remaining fields. Lines with less counts than CPUs, such as ERR and MIS, get the missing ones to 0.
+func parsePerCPULine(line string, numCPU int) (name string, counts []uint64, rest []string, ok bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || !strings.HasSuffix(fields[0], ":") {
+		return "", nil, nil, false
+	}
+	name = strings.TrimSuffix(fields[0], ":")
+	counts = make([]uint64, numCPU)
+	i := 1
+	for ; i < len(fields) && i <= numCPU; i++ {
+		v, err := strconv.ParseUint(fields[i], 10, 64)
+		if err != nil {
+			break
+		}
+		counts[i-1] = v
+	}
+	return name, counts, fields[i:], true
+}
+
+// subPerCPU subtracts from counts those of prev for the same CPUs, which may differ after CPU hotplug.
+func subPerCPU(cpus []int, counts []uint64, prevCPUs []int, prevcounts []uint64) {
+	index := make(map[int]int, len(prevCPUs))
+	for i, cpu := range prevCPUs {
+		index[cpu] = i
+	}
+	for i, cpu := range cpus {
+		if j, ok := index[cpu]; ok && j < len(prevcounts) && i < len(counts) {
+			counts[i] -= prevcounts[j]
+		}
+	}
+}
+
+func addPerCPU(cpus []int, counts []uint64, otherCPUs []int, othercounts []uint64) {
+	index := make(map[int]int, len(otherCPUs))
+	for i, cpu := range otherCPUs {
+		index[cpu] = i
+	}
+	for i, cpu := range cpus {
+		if j, ok := index[cpu]; ok && j < len(othercounts) && i < len(counts) {
+			counts[i] += othercounts[j]
+		}
+	}
+}
+
+// definition comes from Linux kernel /fs/proc/loadavg.c
+type LoadAvg struct {
+	Load1   float64 // number of jobs in the run queue (state R) or waiting for disk I/O (state D) averaged over 1 minute.
+	Load5   float64
+	Load15  float64
+	Running uint64 // number of currently runnable kernel scheduling entities (processes, threads).
+	Total   uint64 // number of kernel scheduling entities that currently exist on the system.
+	LastPid int    // PID of the process that was most recently created on the system.
+}
+
+func (la *LoadAvg) Get() error {
+	raw, err := ioutil.ReadFile(ProcRoot + "/loadavg")
+	if err != nil {
+		return err
+	}
+	return la.Parse(raw)
+}
+
+func (la *LoadAvg) Parse(raw []byte) error {
+	n, err := fmt.Sscanf(string(raw), "%f %f %f %d/%d %d", &la.Load1, &la.Load5, &la.Load15, &la.Running, &la.Total, &la.LastPid)
+	if err != nil {
+		return fmt.Errorf("scan error:[%v] with [%d] succeeded", err, n)
+	}
+	return nil
+}
+
+// SystemPressure is the pressure stall information of the whole system, from /proc/pressure.
+// It requires Linux 4.20 built with CONFIG_PSI, the files are missing otherwise.
+type SystemPressure struct {
+	CPU    *Pressure
+	Memory *Pressure
+	IO     *Pressure
+}
+
+func (sp *SystemPressure) Get() error {
+	for _, f := range []struct {
+		name     string
+		pressure **Pressure
+	}{
+		{"cpu", &sp.CPU},
+		{"memory", &sp.Memory},
+		{"io", &sp.IO},
+	} {
+		raw, err := ioutil.ReadFile(ProcRoot + "/pressure/" + f.name)
+		if err != nil {
+			return err
+		}
+		*f.pressure = new(Pressure)
+		if err = (*f.pressure).Parse(raw); err != nil {
+			return fmt.Errorf("parse %s pressure error:[%v]", f.name, err)
+		}
+	}
+	return nil
+}
+
+// Sub turns the stall totals into their increase since prev.
+func (sp *SystemPressure) Sub(prev *SystemPressure) {
+	subPressure(sp.CPU, prev.CPU)
+	subPressure(sp.Memory, prev.Memory)
+	subPressure(sp.IO, prev.IO)
+}
+
+func (sp *SystemPressure) Add(other *SystemPressure) {
+	addPressure(sp.CPU, other.CPU)
+	addPressure(sp.Memory, other.Memory)
+	addPressure(sp.IO, other.IO)
+}
+
+func (sp *SystemPressure) Div(n uint64) {
+	divPressure(sp.CPU, n)
+	divPressure(sp.Memory, n)
+	divPressure(sp.IO, n)
+}
+
+// VMStat holds the counters of /proc/vmstat keyed by name, such as pgscan_kswapd, pgsteal_direct, oom_kill,
+// thp_fault_alloc or numa_miss. Being a map, the counters added by new kernels show up without change.
+type VMStat map[string]uint64
+
+func NewVMStat() VMStat {
+	return make(VMStat)
+}
+
+func (vs VMStat) Get() error {
+	raw, err := ioutil.ReadFile(ProcRoot + "/vmstat")
+	if err != nil {
+		return err
+	}
+	return vs.Parse(raw)
+}
+
+func (vs VMStat) Parse(raw []byte) error {
+	return parseFlatKeyed(raw, func(key string, v uint64) {
+		vs[key] = v
+	})
+}
+
+// VMStatIsGauge tells whether the vmstat entry is a current value, such as nr_free_pages,
+// rather than a counter increasing since boot.
+func VMStatIsGauge(key string) bool {
+	switch key {
+	case "nr_dirtied", "nr_written":
+		return false
+	case "workingset_nodes":
+		return true
+	}
+	return strings.HasPrefix(key, "nr_")
+}
+
+// Sum adds the entries starting with prefix, Sum("pgscan_") is the pages scanned by kswapd, direct reclaim and khugepaged.
+func (vs VMStat) Sum(prefix string) (sum uint64) {
+	for key, v := range vs {
+		if strings.HasPrefix(key, prefix) {
+			sum += v
+		}
+	}
+	return sum
+}
+
+// Sub turns the counters into their increase since prev, gauges keep their current value.
+// Counters absent from prev have no delta and are dropped.
+func (vs VMStat) Sub(prev VMStat) {
+	for key, v := range vs {
+		if VMStatIsGauge(key) {
+			continue
+		}
+		prevv, ok := prev[key]
+		if !ok {
+			delete(vs, key)
+			continue
+		}
+		vs[key] = v - prevv
+	}
+}
+
+// Add sums every entry of other, gauges included, so that Div averages them.
+func (vs VMStat) Add(other VMStat) {
+	for key, v := range other {
+		if _, ok := vs[key]; ok {
+			vs[key] += v
+		}
+	}
+}
+
+func (vs VMStat) Div(n uint64) {
+	for key := range vs {
+		vs[key] /= n
+	}
+}
+
 // definition comes from Linux kernel /fs/proc/uptime.c
 type Uptime struct {
 	Uptime float64
@@ -0,0 +1,99 @@
+// +build linux
+
+package psss
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// SchedPolicy names the ProcStat.Policy values, from the SCHED_* constants in linux/sched.h.
+var SchedPolicy = map[uint32]string{
+	0: "SCHED_OTHER",
+	1: "SCHED_FIFO",
+	2: "SCHED_RR",
+	3: "SCHED_BATCH",
+	5: "SCHED_IDLE",
+	6: "SCHED_DEADLINE",
+}
+
+// GetThreads reads /proc/[pid]/task/[tid]/stat for every thread of the process.
+// The stat of a thread is that of a process whose Pid is the thread id and Name the thread name,
+// its CPU ticks, processor and policy being those of the thread alone.
+func (p *ProcInfo) GetThreads() error {
+	taskPath := ProcRoot + "/" + strconv.Itoa(p.Stat.Pid) + "/task/"
+	fd, err := os.Open(taskPath)
+	if err != nil {
+		return err
+	}
+	names, err := fd.Readdirnames(-1)
+	fd.Close()
+	if err != nil {
+		return err
+	}
+	threads := make(map[int]*ProcStat, len(names))
+	for _, name := range names {
+		raw, err := ioutil.ReadFile(taskPath + name + "/stat")
+		if err != nil {
+			// exited since the listing
+			continue
+		}
+		st := new(ProcStat)
+		if err = ParseProcStat(raw, st); err != nil {
+			return err
+		}
+		threads[st.Pid] = st
+	}
+	p.Threads = threads
+	return nil
+}
+
+// readThreads is the worker counterpart of ProcInfo.GetThreads.
+func (w *procWorker) readThreads(p *ProcInfo) error {
+	tids, err := w.readTids(p)
+	if err != nil {
+		return err
+	}
+	threads := make(map[int]*ProcStat, len(tids))
+	for _, tid := range tids {
+		raw, err := w.readFile(p.Stat.Pid, "task/"+strconv.Itoa(tid)+"/stat")
+		if err != nil {
+			continue
+		}
+		st := new(ProcStat)
+		if err = ParseProcStat(raw, st); err != nil {
+			return err
+		}
+		threads[tid] = st
+	}
+	p.Threads = threads
+	return nil
+}
+
+// readTids lists /proc/[pid]/task.
+func (w *procWorker) readTids(p *ProcInfo) ([]int, error) {
+	fd, err := w.openat(w.path(p.Stat.Pid, "task"), unix.O_DIRECTORY)
+	if err != nil {
+		return nil, err
+	}
+	defer unix.Close(fd)
+	tids := make([]int, 0, p.Stat.NumThreads)
+	var n int
+	for {
+		if n, err = unix.Getdents(fd, w.fdDirents); err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			break
+		}
+		forEachDirent(w.fdDirents[:n], func(name []byte) {
+			if tid, ok := parseDecimal(name); ok {
+				tids = append(tids, int(tid))
+			}
+		})
+	}
+	return tids, nil
+}
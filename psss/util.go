@@ -24,3 +24,15 @@ func BwToStr(bw float64) string {
 	}
 	return fmt.Sprintf("%g", bw)
 }
+
+// ParseWarning is a problem met while parsing a proc file which did not prevent reading the rest of it,
+// such as a malformed value. The parsers keep them on their result instead of failing.
+type ParseWarning struct {
+	Source string // the file parsed, such as /proc/meminfo
+	Line   string
+	Err    error
+}
+
+func (pw *ParseWarning) Error() string {
+	return fmt.Sprintf("%s: line:[%s] error:[%v]", pw.Source, pw.Line, pw.Err)
+}
@@ -112,7 +112,7 @@ func (t *Topology) GetProcInfo() (err error) {
 	}
 
 	var serviceInfo *ServiceInfo
-	go psss.ScanProcFS(true)
+	go psss.ScanProcFS(psss.ProcReadFds)
 	for originProcInfo = range psss.ProcInfoChan {
 		if originProcInfo.IsEnd {
 			return nil